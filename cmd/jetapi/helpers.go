@@ -1,17 +1,27 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/macsencasaus/jetapi/internal/apikeys"
+	"github.com/macsencasaus/jetapi/internal/export"
+	"github.com/macsencasaus/jetapi/internal/images"
 	"github.com/macsencasaus/jetapi/internal/sites"
+	"github.com/macsencasaus/jetapi/internal/storage"
 )
 
 func (app *application) logErr(err error) {
@@ -37,6 +47,244 @@ func (app *application) badRequest(w http.ResponseWriter) {
 	app.clientError(w, http.StatusBadRequest)
 }
 
+func (app *application) writeJSON(w http.ResponseWriter, v any) {
+	app.writeJSONStatus(w, http.StatusOK, v)
+}
+
+func (app *application) writeJSONStatus(w http.ResponseWriter, status int, v any) {
+	jsonResult, err := json.Marshal(v)
+	if err != nil {
+		app.serverError(w, fmt.Errorf("Error encoding json: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonResult)
+}
+
+// writeTable writes the flights or photos of results as a CSV or TSV
+// attachment named after name.
+func (app *application) writeTable(
+	w http.ResponseWriter,
+	format string,
+	table export.Table,
+	name string,
+	results map[string]*sites.ScrapeResult,
+) {
+	comma := setTableHeaders(w, format, table, name)
+	err := export.WriteTable(w, comma, table, results)
+	if err != nil {
+		app.logErr(fmt.Errorf("Error writing %s: %v", format, err))
+	}
+}
+
+// writeAircraftTable writes the aircraft of a fleet as a CSV or TSV
+// attachment named after name.
+func (app *application) writeAircraftTable(
+	w http.ResponseWriter,
+	format string,
+	name string,
+	aircraft []*sites.FleetAircraft,
+) {
+	comma := setTableHeaders(w, format, export.TableAircraft, name)
+	err := export.WriteAircraft(w, comma, aircraft)
+	if err != nil {
+		app.logErr(fmt.Errorf("Error writing %s: %v", format, err))
+	}
+}
+
+// setTableHeaders sets the headers of a CSV or TSV attachment and
+// returns the separator of its fields.
+func setTableHeaders(w http.ResponseWriter, format string, table export.Table, name string) rune {
+	contentType, comma := "text/csv", ','
+	if format == "tsv" {
+		contentType, comma = "text/tab-separated-values", '\t'
+	}
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, table, format))
+	return comma
+}
+
+// writeGeoJSON writes the routes of flights as a GeoJSON
+// FeatureCollection.
+func (app *application) writeGeoJSON(w http.ResponseWriter, flights []*sites.FlightLeg) {
+	jsonResult, err := json.Marshal(export.FlightsGeoJSON(flights))
+	if err != nil {
+		app.serverError(w, fmt.Errorf("Error encoding geojson: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.Write(jsonResult)
+}
+
+// flightLegs pairs each of flights with reg.
+func flightLegs(reg string, flights []*sites.FlightAttributes) []*sites.FlightLeg {
+	legs := make([]*sites.FlightLeg, len(flights))
+	for i, f := range flights {
+		legs[i] = &sites.FlightLeg{FlightAttributes: *f, Reg: reg}
+	}
+	return legs
+}
+
+// allowRequest charges cost more requests to the API key of r, or
+// responds with a JSON error and returns false.
+func (app *application) allowRequest(w http.ResponseWriter, r *http.Request, cost int) bool {
+	return app.charge(w, r, apiKeyFrom(r), cost)
+}
+
+// allowScrape charges the upstream requests a handler is about to make
+// on top of the request itself, like allowRequest. The cost is capped at
+// the rate limit of the key less the request's own token, so that no
+// request is too large to ever be allowed.
+func (app *application) allowScrape(w http.ResponseWriter, r *http.Request, cost int) bool {
+	cost = min(cost, app.accounts.RateLimit(apiKeyFrom(r))-1)
+	if cost < 1 {
+		return true
+	}
+	return app.allowRequest(w, r, cost)
+}
+
+// aircraftCost is the number of upstream requests of a full lookup of an
+// aircraft: its JetPhotos search, a page per photo, the two searches
+// dating its photos and its FR24 page.
+func aircraftCost(q *sites.APIQueries) int {
+	if q.Photos == 0 {
+		return 2
+	}
+	return 4 + q.Photos
+}
+
+// requireKey returns the API key of r, or responds with 401
+// Unauthorized and returns false if there isn't one.
+func (app *application) requireKey(w http.ResponseWriter, r *http.Request) (*apikeys.Key, bool) {
+	k := apiKeyFrom(r)
+	if k == nil {
+		app.errorJSON(w, http.StatusUnauthorized, errors.New("API key required"), 0)
+		return nil, false
+	}
+	return k, true
+}
+
+// charge takes cost requests from the limits of k, or of the client IP
+// when k is nil, responding with 429 Too Many Requests if they are over.
+func (app *application) charge(w http.ResponseWriter, r *http.Request, k *apikeys.Key, cost int) bool {
+	retry, err := app.accounts.Take(k, app.clientIP(r), cost, time.Now())
+	if err == nil {
+		return true
+	}
+	app.errorJSON(w, http.StatusTooManyRequests, err, retry)
+	return false
+}
+
+// errorJSON responds with status and a JSON body describing err. A
+// non-zero retry is sent as Retry-After, rounded up to a second.
+func (app *application) errorJSON(w http.ResponseWriter, status int, err error, retry time.Duration) {
+	body := struct {
+		Error      string `json:"Error"`
+		RetryAfter int    `json:"RetryAfter,omitempty"`
+	}{Error: err.Error()}
+
+	if retry > 0 {
+		body.RetryAfter = int(math.Ceil(retry.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(body.RetryAfter))
+	}
+
+	b, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// clientIP returns the IP of the client of r. When r comes from one of
+// the trusted proxies, it is the last address in X-Forwarded-For that
+// wasn't added by one of them.
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0 && app.trustedProxy(ip); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+	}
+	return ip
+}
+
+func (app *application) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range app.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma separated list of IPs and CIDR
+// ranges.
+func parseTrustedProxies(s string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", p)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// requestURL returns the absolute URL r was made to, leaving out the
+// API key.
+func requestURL(r *http.Request) string {
+	u := *r.URL
+	query := u.Query()
+	if query.Has("api_key") {
+		query.Del("api_key")
+		u.RawQuery = query.Encode()
+	}
+	return baseURL(r) + u.RequestURI()
+}
+
+// baseURL returns the scheme and host r was made to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+func (app *application) saveSnapshot(reg string, sr *sites.ScrapeResult) {
+	err := app.store.Save(storage.NewSnapshot(reg, sr, time.Now()))
+	if err != nil {
+		app.logErr(fmt.Errorf("Error saving snapshot: %v", err))
+	}
+}
+
 func (app *application) render(
 	w http.ResponseWriter,
 	status int,
@@ -73,14 +321,13 @@ func (app *application) parseAPIQueries(
 		return nil, fmt.Errorf("%d", http.StatusNotFound)
 	}
 
-	// is alphanumeric and may include '-'
-	isAlpha := regexp.MustCompile(`^[a-zA-Z0-9-]+$`).MatchString(reg)
-	if !isAlpha {
+	if !isAlphanumeric(reg) {
 		return nil, fmt.Errorf("%d", http.StatusBadRequest)
 	}
 
 	onlyJP := queryParams.Get("only_jp") == "true"
 	onlyFR := queryParams.Get("only_fr") == "true"
+	dedupe := queryParams.Get("dedupe") == "true"
 
 	photos, err := handleNumQuery(queryParams, "photos")
 	if err != nil {
@@ -98,16 +345,238 @@ func (app *application) parseAPIQueries(
 		flights = 20
 	}
 
+	fields, err := sites.ParseFields(queryParams.Get("fields"))
+	if err != nil {
+		return nil, fmt.Errorf("%d", http.StatusBadRequest)
+	}
+
+	detail := sites.DetailLevel(queryParams.Get("detail"))
+	switch detail {
+	case "":
+		detail = sites.DetailFull
+	case sites.DetailFull, sites.DetailNone:
+	default:
+		return nil, fmt.Errorf("%d", http.StatusBadRequest)
+	}
+
+	page, err := handleNumQuery(queryParams, "page")
+	if err != nil || page == 0 {
+		return nil, fmt.Errorf("%d", http.StatusBadRequest)
+	}
+	if page == -1 {
+		page = 1
+	}
+	cursor := sites.Cursor{Page: page}
+
+	if c := queryParams.Get("cursor"); c != "" {
+		cursor, err = sites.ParseCursor(c)
+		if err != nil {
+			return nil, fmt.Errorf("%d", http.StatusBadRequest)
+		}
+	}
+
+	filters, err := parsePhotoFilters(queryParams)
+	if err != nil {
+		return nil, err
+	}
+
 	q := &sites.APIQueries{
 		Reg:     reg,
 		Photos:  photos,
 		Flights: flights,
 		OnlyJP:  onlyJP,
 		OnlyFR:  onlyFR,
+		Fields:  fields,
+		Detail:  detail,
+		Cursor:  cursor,
+		Filters: filters,
+		Dedupe:  dedupe,
+	}
+	// a cursor only makes sense for the search it came from
+	if !cursor.Matches(q) {
+		return nil, fmt.Errorf("%d", http.StatusBadRequest)
 	}
 	return q, nil
 }
 
+// media types of the output formats, for the Accept header
+var formatTypes = map[string]string{
+	"json":    "application/json",
+	"csv":     "text/csv",
+	"tsv":     "text/tab-separated-values",
+	"geojson": "application/geo+json",
+}
+
+// parseFormat returns the output format asked for by the format query,
+// or else by the Accept header, defaulting to json. Formats other than
+// json must be one of formats.
+func parseFormat(r *http.Request, formats ...string) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return negotiateFormat(r.Header.Get("Accept"), formats), nil
+	}
+
+	if format != "json" && !slices.Contains(formats, format) {
+		return "", fmt.Errorf("%d", http.StatusBadRequest)
+	}
+	return format, nil
+}
+
+// negotiateFormat returns json or the one of formats that accept gives
+// the highest q-value, the one whose range is listed first on a tie.
+// An Accept header the endpoint can't serve falls back to json.
+func negotiateFormat(accept string, formats []string) string {
+	best, bestQ, bestPos := "json", 0.0, 0
+	for _, format := range append([]string{"json"}, formats...) {
+		q, pos := acceptWeight(accept, formatTypes[format])
+		if q > bestQ || (q > 0 && q == bestQ && pos < bestPos) {
+			best, bestQ, bestPos = format, q, pos
+		}
+	}
+	return best
+}
+
+// acceptWeight returns the q-value accept gives mediaType, from the most
+// specific media range matching it, and the position of that range.
+func acceptWeight(accept, mediaType string) (float64, int) {
+	q, pos, matched := 0.0, 0, -1
+	for i, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(part, ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+
+		// 2 for the type itself, 1 for type/* and 0 for */*
+		specificity := -1
+		switch {
+		case mediaRange == mediaType:
+			specificity = 2
+		case mediaRange == "*/*":
+			specificity = 0
+		case strings.HasSuffix(mediaRange, "/*") &&
+			strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+			specificity = 1
+		}
+		if specificity <= matched {
+			continue
+		}
+
+		matched, pos, q = specificity, i, 1
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(key, "q") {
+				continue
+			}
+			if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 && v <= 1 {
+				q = v
+			}
+		}
+	}
+	return q, pos
+}
+
+// parseTable returns the table asked for by the table query, or def.
+// Tables other than flights and photos must be one of more.
+func parseTable(qp url.Values, def export.Table, more ...export.Table) (export.Table, error) {
+	switch table := export.Table(qp.Get("table")); table {
+	case "":
+		return def, nil
+	case export.TableFlights, export.TablePhotos:
+		return table, nil
+	default:
+		if slices.Contains(more, table) {
+			return table, nil
+		}
+		return "", fmt.Errorf("%d", http.StatusBadRequest)
+	}
+}
+
+func parseImageOptions(qp url.Values) (images.Options, error) {
+	width, err := handleNumQuery(qp, "w")
+	if err != nil || width > images.MaxDimension {
+		return images.Options{}, fmt.Errorf("%d", http.StatusBadRequest)
+	}
+
+	height, err := handleNumQuery(qp, "h")
+	if err != nil || height > images.MaxDimension {
+		return images.Options{}, fmt.Errorf("%d", http.StatusBadRequest)
+	}
+
+	opts := images.Options{Width: max(width, 0), Height: max(height, 0)}
+
+	switch qp.Get("fmt") {
+	case "":
+	case "jpeg", "jpg":
+		opts.Format = images.FormatJPEG
+	case "png":
+		opts.Format = images.FormatPNG
+	default:
+		return images.Options{}, fmt.Errorf("%d", http.StatusBadRequest)
+	}
+	return opts, nil
+}
+
+func parsePhotoFilters(qp url.Values) (sites.PhotoFilters, error) {
+	f := sites.PhotoFilters{
+		Location:     qp.Get("location"),
+		Photographer: qp.Get("photographer"),
+		Airline:      qp.Get("airline"),
+		Sort:         sites.PhotoSort(qp.Get("sort")),
+	}
+
+	switch f.Sort {
+	case "", sites.SortUploaded, sites.SortTaken, sites.SortViews:
+	default:
+		return f, fmt.Errorf("%d", http.StatusBadRequest)
+	}
+
+	var err error
+	if after := qp.Get("taken_after"); after != "" {
+		f.TakenAfter, err = sites.ParseDateBound(after, false)
+		if err != nil {
+			return f, fmt.Errorf("%d", http.StatusBadRequest)
+		}
+	}
+	if before := qp.Get("taken_before"); before != "" {
+		f.TakenBefore, err = sites.ParseDateBound(before, true)
+		if err != nil {
+			return f, fmt.Errorf("%d", http.StatusBadRequest)
+		}
+	}
+	return f, nil
+}
+
+// is alphanumeric and may include '-'
+var alphanumeric = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+func isAlphanumeric(s string) bool {
+	return alphanumeric.MatchString(s)
+}
+
+// IATA codes are 3 letters, ICAO codes 4 letters or digits
+var airportCode = regexp.MustCompile(`^[a-zA-Z0-9]{3,4}$`)
+
+func isAirportCode(s string) bool {
+	return airportCode.MatchString(s)
+}
+
+func parseWatchQueries(qp url.Values) (int, int, error) {
+	photos, err := handleNumQuery(qp, "photos")
+	if err != nil {
+		return 0, 0, err
+	}
+	if photos == -1 {
+		photos = 3
+	}
+
+	flights, err := handleNumQuery(qp, "flights")
+	if err != nil {
+		return 0, 0, err
+	}
+	if flights == -1 {
+		flights = 20
+	}
+	return photos, flights, nil
+}
+
 func handleNumQuery(qp url.Values, query string) (int, error) {
 	resStr := qp.Get(query)
 	if resStr == "" {
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		accept  string
+		formats []string
+		want    string
+		wantErr bool
+	}{
+		{"no preference", "", "", []string{"csv", "tsv"}, "json", false},
+		{"accept csv", "", "text/csv", []string{"csv", "tsv"}, "csv", false},
+		{"refused csv", "", "text/csv;q=0, application/json", []string{"csv", "tsv"}, "json", false},
+		{"weighted csv", "", "application/json;q=0.5, text/csv", []string{"csv", "tsv"}, "csv", false},
+		{"weighted tsv", "", "text/csv;q=0.4, text/tab-separated-values;q=0.8", []string{"csv", "tsv"}, "tsv", false},
+		{"first on a tie", "", "text/tab-separated-values, text/csv", []string{"csv", "tsv"}, "tsv", false},
+		{"wildcard", "", "text/html, */*;q=0.8", []string{"csv", "tsv"}, "json", false},
+		{"type wildcard", "", "text/*", []string{"csv", "tsv"}, "csv", false},
+		{"specific over wildcard", "", "text/*, text/csv;q=0", []string{"csv", "tsv"}, "tsv", false},
+		{"unservable", "", "text/csv", []string{"geojson"}, "json", false},
+		{"geojson", "", "application/geo+json", []string{"geojson"}, "geojson", false},
+		{"query wins", "format=tsv", "text/csv", []string{"csv", "tsv"}, "tsv", false},
+		{"unknown query", "format=xml", "", []string{"csv", "tsv"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			got, err := parseFormat(r, tt.formats...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
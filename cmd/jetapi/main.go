@@ -1,18 +1,46 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync/atomic"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/apikeys"
+	"github.com/macsencasaus/jetapi/internal/cache"
+	"github.com/macsencasaus/jetapi/internal/changes"
+	"github.com/macsencasaus/jetapi/internal/images"
+	"github.com/macsencasaus/jetapi/internal/refdata"
+	"github.com/macsencasaus/jetapi/internal/sites"
+	"github.com/macsencasaus/jetapi/internal/storage"
+	"github.com/macsencasaus/jetapi/internal/watch"
+	"github.com/macsencasaus/jetapi/internal/webhook"
 )
 
 type application struct {
 	errorLog      *log.Logger
 	infoLog       *log.Logger
 	templateCache map[string]*template.Template
+	store         storage.Store
+	watchlist     *watch.List
+	webhooks      *webhook.Registry
+	dispatcher    *webhook.Dispatcher
+	hub           *watch.Hub
+	feedCache     *cache.Cache[*sites.JetPhotosResult]
+	imageProxy    *images.Proxy
+	accounts      *apikeys.Accounts
+	// proxies whose X-Forwarded-For is believed for client IPs
+	trustedProxies []*net.IPNet
+
+	archiveMaxPhotos int
+	archiveMaxBytes  int64
 
 	apiCalls     atomic.Uint64
 	totalLatency atomic.Int64 // stored as nanoseconds
@@ -34,15 +62,142 @@ func main() {
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	if path := os.Getenv("AIRLINES_FILE"); path != "" {
+		err := refdata.LoadAirlinesFile(path)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Loaded airlines from %s", path)
+	}
+
+	if path := os.Getenv("AIRPORTS_FILE"); path != "" {
+		err := refdata.LoadAirportsFile(path)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Loaded airports from %s", path)
+	}
+
 	templateCache, err := newTemplateCache()
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+
+	fileStore, err := storage.NewFileStore(dataDir)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	watchlist, err := watch.OpenList(filepath.Join(dataDir, "watchlist.json"))
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// webhooks may only post to private addresses when trying them out
+	allowPrivateHooks := os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+	webhooks, err := webhook.OpenRegistry(filepath.Join(dataDir, "webhooks.json"), allowPrivateHooks)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	dispatcher := webhook.NewDispatcher(webhooks, errorLog)
+
+	// canonical snapshots are checked for changes to notify about
+	var hub *watch.Hub
+	store := storage.WithHook(fileStore, func(prev, next *storage.Snapshot) {
+		events := changes.Diff(prev, next)
+		dispatcher.Dispatch(events)
+		hub.Publish(events)
+	})
+
+	watchInterval, err := durationEnv("WATCH_INTERVAL", 30*time.Minute)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	streamInterval, err := durationEnv("STREAM_INTERVAL", time.Minute)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	hub = watch.NewHub(store, streamInterval, errorLog)
+
+	feedCacheTTL, err := durationEnv("FEED_CACHE_TTL", 15*time.Minute)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	imageCacheMB, err := intEnv("IMAGE_CACHE_MB", 512)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	imageCache, err := images.OpenDiskCache(filepath.Join(dataDir, "images"), int64(imageCacheMB)<<20)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// limits of requests without an API key
+	rateLimit, err := intEnv("RATE_LIMIT", 30)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	anonQuota, err := intEnv("ANON_DAILY_QUOTA", 1000)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	keysFile := os.Getenv("API_KEYS_FILE")
+	if keysFile == "" {
+		keysFile = filepath.Join(dataDir, "apikeys.json")
+	}
+	accounts, err := apikeys.Open(keysFile, filepath.Join(dataDir, "usage.json"),
+		apikeys.Limits{RateLimit: rateLimit, DailyQuota: anonQuota})
+	if err != nil {
+		errorLog.Fatalf("loading API keys: %v", err)
+	}
+
+	// without any, the server must be reached directly for per IP
+	// limits to work
+	trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	archiveMaxPhotos, err := intEnv("ARCHIVE_MAX_PHOTOS", 25)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	// an archive takes a token per photo on top of the request, so a
+	// bigger one could never be allowed without a key
+	if archiveMaxPhotos+1 > rateLimit {
+		errorLog.Fatalf("ARCHIVE_MAX_PHOTOS %d must be below RATE_LIMIT %d", archiveMaxPhotos, rateLimit)
+	}
+
+	archiveMaxMB, err := intEnv("ARCHIVE_MAX_MB", 200)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	app := &application{
 		errorLog:      errorLog,
 		infoLog:       infoLog,
 		templateCache: templateCache,
+		store:         store,
+		watchlist:     watchlist,
+		webhooks:      webhooks,
+		dispatcher:    dispatcher,
+		hub:           hub,
+		feedCache:     cache.New[*sites.JetPhotosResult](feedCacheTTL),
+		imageProxy:    images.NewProxy(imageCache),
+		accounts:      accounts,
+
+		trustedProxies: trustedProxies,
+
+		archiveMaxPhotos: archiveMaxPhotos,
+		archiveMaxBytes:  int64(archiveMaxMB) << 20,
 	}
 
 	srv := &http.Server{
@@ -54,7 +209,37 @@ func main() {
 	app.infoLog.Print("Starting stats logger")
 	go app.statsLogger()
 
+	app.infoLog.Printf("Starting watchlist scheduler, refreshing every %s", watchInterval)
+	scheduler := watch.NewScheduler(watchlist, store, watchInterval, errorLog)
+	go scheduler.Run(context.Background())
+
+	go accounts.Run(context.Background(), time.Minute, errorLog)
+
 	app.infoLog.Printf("Starting server on %s", addr)
 	err = srv.ListenAndServe()
 	errorLog.Fatal(err)
 }
+
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, val)
+	}
+	return d, nil
+}
+
+func intEnv(name string, def int) (int, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, val)
+	}
+	return n, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/macsencasaus/jetapi/internal/apikeys"
+)
+
+type contextKey string
+
+const apiKeyContextKey = contextKey("apiKey")
+
+// paths under these prefixes, and /api itself, are charged to a key
+var chargedPrefixes = []string{"/api/", "/feeds/", "/img/"}
+
+// requireAPIKey charges every request to the API, feeds and images to
+// the API key given in the X-API-Key header or api_key query, or to the
+// client's IP without one. The key is passed on in the context.
+func (app *application) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !charged(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = r.URL.Query().Get("api_key")
+		}
+
+		k, err := app.accounts.Lookup(key)
+		if err != nil {
+			app.errorJSON(w, http.StatusUnauthorized, err, 0)
+			return
+		}
+
+		if !app.charge(w, r, k, 1) {
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyContextKey, k)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiKeyFrom returns the API key of r, or nil.
+func apiKeyFrom(r *http.Request) *apikeys.Key {
+	k, _ := r.Context().Value(apiKeyContextKey).(*apikeys.Key)
+	return k
+}
+
+func charged(path string) bool {
+	if path == "/api" {
+		return true
+	}
+	for _, prefix := range chargedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,16 +2,39 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/macsencasaus/jetapi/internal/export"
+	"github.com/macsencasaus/jetapi/internal/refdata"
 	"github.com/macsencasaus/jetapi/internal/sites"
+	"github.com/macsencasaus/jetapi/internal/storage"
+	"github.com/macsencasaus/jetapi/internal/watch"
+	"github.com/macsencasaus/jetapi/internal/webhook"
 )
 
 type handlerFunc = func(http.ResponseWriter, *http.Request)
 
-func (app *application) routes() *http.ServeMux {
+// each photo in a history scan is its own JetPhotos request
+const maxHistoryPhotos = 200
+
+// newest flights of the flight log put in a calendar
+const maxCalendarFlights = 100
+
+const maxFeedPhotos = 20
+
+// aircraft of a fleet enriched per request, each a full lookup
+const maxFleetEnrich = 25
+
+// flights of a flight number read with chain=true, each aircraft
+// flying them a full lookup
+const maxChainFlights = 10
+
+func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	fileServer := http.FileServer(http.Dir("./ui/static"))
@@ -19,11 +42,30 @@ func (app *application) routes() *http.ServeMux {
 
 	mux.HandleFunc("/", app.home)
 	mux.HandleFunc("/api", app.api)
+	mux.HandleFunc("/api/photographer", app.photographer)
+	mux.HandleFunc("/api/flight", app.flight)
+	mux.HandleFunc("/api/fleet", app.fleet)
+	mux.HandleFunc("/api/airport", app.airport)
+	mux.HandleFunc("/api/history", app.history)
+	mux.HandleFunc("/api/calendar.ics", app.calendar)
+	mux.HandleFunc("/api/archive.zip", app.archive)
+	mux.HandleFunc("/api/stream", app.stream)
+	mux.HandleFunc("/api/watchlist", app.watchlistHandler)
+	mux.HandleFunc("/api/watchlist/{reg}", app.watchedAircraft)
+	mux.HandleFunc("/api/webhooks", app.webhooksHandler)
+	mux.HandleFunc("/api/webhooks/{id}", app.webhookHandler)
+	mux.HandleFunc("/api/webhooks/deliveries", app.webhookDeliveries)
+	mux.HandleFunc("/api/admin/usage", app.usage)
+	mux.HandleFunc("/api/types/{code}", app.aircraftType)
+	mux.HandleFunc("/api/airlines/{code}", app.airline)
+	mux.HandleFunc("/feeds/photos.atom", app.photoFeed)
+	mux.HandleFunc("/feeds/photos.rss", app.photoFeed)
+	mux.HandleFunc("/img/{id}", app.image)
 	mux.HandleFunc("/aircraft", app.aircraftSearch)
 	mux.HandleFunc("/documentation", app.documentation)
 	mux.HandleFunc("/querybuilder", app.queryBuilder)
 
-	return mux
+	return app.requireAPIKey(mux)
 }
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
@@ -58,9 +100,31 @@ func (app *application) api(w http.ResponseWriter, r *http.Request) {
 		app.badRequest(w)
 		return
 	}
+
+	format, err := parseFormat(r, "csv", "tsv", "geojson")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+	// routes only need the flights
+	if format == "geojson" {
+		q.Photos = 0
+	}
+
+	defaultTable := export.TableFlights
+	if q.OnlyJP {
+		defaultTable = export.TablePhotos
+	}
+	table, err := parseTable(r.URL.Query(), defaultTable)
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
 	countable = true
 
-	var jsonResult []byte
+	var result any
+	var scraped *sites.ScrapeResult
+	fields := q.Fields
 
 	if q.OnlyJP == q.OnlyFR {
 		sr, err := sites.Scrape(q)
@@ -73,15 +137,23 @@ func (app *application) api(w http.ResponseWriter, r *http.Request) {
 			app.logErr(fmt.Errorf("Partial Error: %v", err))
 		}
 
-		jsonResult, err = json.Marshal(sr)
+		app.saveSnapshot(q.Reg, sr)
+		result = sr
+		scraped = sr
 	} else if q.OnlyJP {
 		jpRes, err := sites.ScrapeJetPhotos(q)
-		if err != nil {
+		if jpRes == nil {
 			app.notFound(w)
 			return
 		}
+		if err != nil {
+			app.logErr(fmt.Errorf("Partial Error: %v", err))
+		}
 
-		jsonResult, err = json.Marshal(jpRes)
+		scraped = &sites.ScrapeResult{JetPhotos: jpRes}
+		app.saveSnapshot(q.Reg, scraped)
+		result = jpRes
+		fields = fields.Sub("JetPhotos")
 	} else if q.OnlyFR {
 		frRes, err := sites.ScrapeFlightRadar(q)
 		if err != nil {
@@ -89,16 +161,877 @@ func (app *application) api(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		jsonResult, err = json.Marshal(frRes)
+		scraped = &sites.ScrapeResult{FlightRadar: frRes}
+		app.saveSnapshot(q.Reg, scraped)
+		result = frRes
+		fields = fields.Sub("FlightRadar")
+	}
+
+	if format == "geojson" {
+		flights := []*sites.FlightAttributes{}
+		if scraped.FlightRadar != nil {
+			flights = scraped.FlightRadar.Flights
+		}
+		app.writeGeoJSON(w, flightLegs(q.Reg, flights))
+		return
+	}
+
+	if format != "json" {
+		results := map[string]*sites.ScrapeResult{q.Reg: scraped}
+		app.writeTable(w, format, table, q.Reg, results)
+		return
 	}
 
+	result, err = fields.Trim(result)
 	if err != nil {
 		app.serverError(w, fmt.Errorf("Error encoding json: %v", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsonResult)
+	app.writeJSON(w, result)
+}
+
+func (app *application) photographer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	name := queryParams.Get("name")
+	if name == "" {
+		app.badRequest(w)
+		return
+	}
+
+	photos, err := handleNumQuery(queryParams, "photos")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+	if photos == -1 {
+		photos = 3
+	}
+
+	// the search, a page per photo and the profile page
+	if photos > 0 && !app.allowScrape(w, r, photos+2) {
+		return
+	}
+
+	res, err := sites.ScrapePhotographer(name, photos)
+	if res == nil {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.logErr(fmt.Errorf("Partial Error: %v", err))
+	}
+
+	app.writeJSON(w, res)
+}
+
+func (app *application) flight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	number := queryParams.Get("number")
+	if !isAlphanumeric(number) {
+		app.badRequest(w)
+		return
+	}
+
+	flights, err := handleNumQuery(queryParams, "flights")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+	if flights == -1 {
+		flights = 20
+	}
+
+	photos, err := handleNumQuery(queryParams, "photos")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+	if photos == -1 {
+		photos = 3
+	}
+
+	format, err := parseFormat(r, "geojson")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+
+	chain := queryParams.Get("chain") == "true"
+	if chain {
+		flights = min(flights, maxChainFlights)
+	}
+
+	q := &sites.FlightQueries{
+		Number:   number,
+		Flights:  flights,
+		Chain:    chain,
+		Aircraft: &sites.APIQueries{Photos: photos, Flights: 20},
+	}
+
+	// each flight may be flown by a different aircraft
+	if chain && !app.allowScrape(w, r, flights*aircraftCost(q.Aircraft)) {
+		return
+	}
+
+	res, err := sites.ScrapeFlightNumber(q)
+	if res == nil {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.logErr(fmt.Errorf("Partial Error: %v", err))
+	}
+
+	if format == "geojson" {
+		app.writeGeoJSON(w, res.Legs)
+		return
+	}
+
+	app.writeJSON(w, res)
+}
+
+func (app *application) fleet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	airline := queryParams.Get("airline")
+	if !isAlphanumeric(airline) {
+		app.badRequest(w)
+		return
+	}
+
+	photos, err := handleNumQuery(queryParams, "photos")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+	if photos == -1 {
+		photos = 3
+	}
+
+	flights, err := handleNumQuery(queryParams, "flights")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+	if flights == -1 {
+		flights = 20
+	}
+
+	limit, err := handleNumQuery(queryParams, "limit")
+	if err != nil || limit == 0 || limit > maxFleetEnrich {
+		app.badRequest(w)
+		return
+	}
+	if limit == -1 {
+		limit = maxFleetEnrich
+	}
+
+	page, err := handleNumQuery(queryParams, "page")
+	if err != nil || page == 0 {
+		app.badRequest(w)
+		return
+	}
+	if page == -1 {
+		page = 1
+	}
+
+	format, err := parseFormat(r, "csv", "tsv")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+
+	enrich := queryParams.Get("enrich") == "true"
+
+	// without enrich the fleet itself is all there is to export
+	defaultTable := export.TableAircraft
+	if enrich {
+		defaultTable = export.TableFlights
+	}
+	table, err := parseTable(queryParams, defaultTable, export.TableAircraft)
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+
+	q := &sites.FleetQueries{
+		Airline:  airline,
+		Enrich:   enrich,
+		Aircraft: &sites.APIQueries{Photos: photos, Flights: flights},
+		Page:     page,
+		Limit:    limit,
+	}
+
+	if enrich && !app.allowScrape(w, r, limit*aircraftCost(q.Aircraft)) {
+		return
+	}
+
+	res, err := sites.ScrapeFleet(q)
+	if res == nil {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.logErr(fmt.Errorf("Partial Error: %v", err))
+	}
+
+	if format != "json" && table == export.TableAircraft {
+		app.writeAircraftTable(w, format, airline, res.Aircraft)
+		return
+	}
+	// flight and photo rows come from the enriched aircraft
+	if format != "json" {
+		app.writeTable(w, format, table, airline, res.Enriched)
+		return
+	}
+
+	app.writeJSON(w, res)
+}
+
+func (app *application) airport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	code := queryParams.Get("code")
+	if !isAirportCode(code) {
+		app.badRequest(w)
+		return
+	}
+
+	flights, err := handleNumQuery(queryParams, "flights")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+	if flights == -1 {
+		flights = 20
+	}
+
+	res, err := sites.ScrapeAirport(code, flights)
+	if err != nil {
+		app.logErr(err)
+		app.notFound(w)
+		return
+	}
+
+	app.writeJSON(w, res)
+}
+
+func (app *application) history(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	reg := queryParams.Get("reg")
+	if !isAlphanumeric(reg) {
+		app.badRequest(w)
+		return
+	}
+
+	photos, err := handleNumQuery(queryParams, "photos")
+	if err != nil || photos > maxHistoryPhotos {
+		app.badRequest(w)
+		return
+	}
+	if photos == -1 {
+		photos = 60
+	}
+
+	format, err := parseFormat(r, "geojson")
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+
+	// source=stored skips JetPhotos and only serves the flight log
+	source := queryParams.Get("source")
+	switch source {
+	case "", "live":
+	case "stored":
+		photos = 0
+	default:
+		app.badRequest(w)
+		return
+	}
+
+	// a request for each photo page
+	if !app.allowScrape(w, r, photos) {
+		return
+	}
+
+	res, err := sites.ScrapeHistory(reg, photos)
+	scraped := err == nil
+	if !scraped {
+		// the stored flights are still worth returning
+		app.logErr(fmt.Errorf("Partial Error: %v", err))
+		res = &sites.HistoryResult{
+			Reg:       strings.ToUpper(reg),
+			Operators: []*sites.OperatorPeriod{},
+		}
+	}
+
+	res.Flights, err = app.store.FlightLog(reg)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !scraped && len(res.Flights) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	if format == "geojson" {
+		app.writeGeoJSON(w, flightLegs(res.Reg, res.Flights))
+		return
+	}
+
+	app.writeJSON(w, res)
+}
+
+func (app *application) calendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	reg := r.URL.Query().Get("reg")
+	if !isAlphanumeric(reg) {
+		app.badRequest(w)
+		return
+	}
+
+	// refresh the flight log so each poll of a subscribed calendar
+	// picks up schedule and status changes
+	q := &sites.APIQueries{Reg: reg, Flights: 20}
+	frRes, err := sites.ScrapeFlightRadar(q)
+	if err != nil {
+		app.logErr(fmt.Errorf("Partial Error: %v", err))
+	} else {
+		app.saveSnapshot(reg, &sites.ScrapeResult{FlightRadar: frRes})
+	}
+
+	flights, err := app.store.FlightLog(reg)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(flights) == 0 {
+		app.notFound(w)
+		return
+	}
+	flights = flights[:min(len(flights), maxCalendarFlights)]
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`inline; filename="%s.ics"`, strings.ToUpper(reg)))
+
+	err = export.WriteCalendar(w, strings.ToUpper(reg), flights, time.Now())
+	if err != nil {
+		app.logErr(fmt.Errorf("Error writing calendar: %v", err))
+	}
+}
+
+func (app *application) archive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	reg := strings.ToUpper(queryParams.Get("reg"))
+	if !isAlphanumeric(reg) {
+		app.badRequest(w)
+		return
+	}
+
+	photos, err := handleNumQuery(queryParams, "photos")
+	if err != nil || photos == 0 || photos > app.archiveMaxPhotos {
+		app.badRequest(w)
+		return
+	}
+
+	// the request itself took a token, so a key can't ever be allowed
+	// more photos than its rate limit less one
+	limit := app.accounts.RateLimit(apiKeyFrom(r)) - 1
+	if photos == -1 {
+		photos = min(20, app.archiveMaxPhotos, limit)
+	}
+	if photos > limit || photos < 1 {
+		err = fmt.Errorf("archives are limited to %d photos by the rate limit of this key", limit)
+		app.errorJSON(w, http.StatusBadRequest, err, 0)
+		return
+	}
+
+	// a token per photo, each costing a request for its photo page
+	// and one for the full-size image, as archives never hash
+	// thumbnails
+	if !app.allowRequest(w, r, photos) {
+		return
+	}
+
+	q := &sites.APIQueries{Reg: reg, Photos: photos, Detail: sites.DetailFull}
+	jpRes, err := sites.ScrapeJetPhotos(q)
+	if jpRes == nil || len(jpRes.Images) == 0 {
+		if err != nil {
+			app.logErr(err)
+		}
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.logErr(fmt.Errorf("Partial Error: %v", err))
+	}
+	app.saveSnapshot(reg, &sites.ScrapeResult{JetPhotos: jpRes})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="%s-photos.zip"`, reg))
+
+	// the response has started, so errors can only cut it short
+	err = export.WriteArchive(w, jpRes, app.archiveMaxBytes, time.Now())
+	if err != nil {
+		app.logErr(fmt.Errorf("Error writing archive: %v", err))
+	}
+}
+
+func (app *application) photoFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	reg := strings.ToUpper(queryParams.Get("reg"))
+	if !isAlphanumeric(reg) {
+		app.badRequest(w)
+		return
+	}
+
+	photos, err := handleNumQuery(queryParams, "photos")
+	if err != nil || photos > maxFeedPhotos {
+		app.badRequest(w)
+		return
+	}
+	if photos == -1 {
+		photos = 10
+	}
+
+	// feed readers poll, so scrapes are shared between them for the
+	// cache TTL
+	key := fmt.Sprintf("%s:%d", reg, photos)
+	jpRes, err := app.feedCache.Get(key, func() (*sites.JetPhotosResult, error) {
+		q := &sites.APIQueries{Reg: reg, Photos: photos, Detail: sites.DetailFull}
+		res, err := sites.ScrapeJetPhotos(q)
+		if res == nil {
+			return nil, err
+		}
+		if err != nil {
+			app.logErr(fmt.Errorf("Partial Error: %v", err))
+		}
+		app.saveSnapshot(reg, &sites.ScrapeResult{JetPhotos: res})
+		return res, nil
+	})
+	if err != nil {
+		app.logErr(err)
+		app.notFound(w)
+		return
+	}
+
+	self := requestURL(r)
+	if strings.HasSuffix(r.URL.Path, ".rss") {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		err = export.WriteRSS(w, jpRes, self, time.Now())
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		err = export.WriteAtom(w, jpRes, baseURL(r), self, time.Now())
+	}
+	if err != nil {
+		app.logErr(fmt.Errorf("Error writing feed: %v", err))
+	}
+}
+
+func (app *application) stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	reg := r.URL.Query().Get("reg")
+	if !isAlphanumeric(reg) {
+		app.badRequest(w)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverError(w, fmt.Errorf("streaming unsupported"))
+		return
+	}
+
+	events, unsubscribe := app.hub.Subscribe(reg)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	// keeps proxies from closing an idle stream
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e := <-events:
+			data, err := json.Marshal(e)
+			if err != nil {
+				app.logErr(fmt.Errorf("Error encoding event: %v", err))
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+func (app *application) watchlistHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		app.writeJSON(w, app.watchlist.All())
+
+	case http.MethodPost:
+		if _, ok := app.requireKey(w, r); !ok {
+			return
+		}
+
+		queryParams := r.URL.Query()
+		reg := queryParams.Get("reg")
+		if !isAlphanumeric(reg) {
+			app.badRequest(w)
+			return
+		}
+
+		photos, flights, err := parseWatchQueries(queryParams)
+		if err != nil {
+			app.badRequest(w)
+			return
+		}
+
+		e, err := app.watchlist.Add(watch.Entry{Reg: reg, Photos: photos, Flights: flights})
+		if errors.Is(err, watch.ErrAlreadyWatched) {
+			app.clientError(w, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.writeJSONStatus(w, http.StatusCreated, e)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		app.clientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (app *application) watchedAircraft(w http.ResponseWriter, r *http.Request) {
+	reg := r.PathValue("reg")
+
+	switch r.Method {
+	case http.MethodGet:
+		e, err := app.watchlist.Get(reg)
+		if err != nil {
+			app.notFound(w)
+			return
+		}
+
+		snapshot, err := app.store.Latest(reg)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			app.serverError(w, err)
+			return
+		}
+
+		app.writeJSON(w, struct {
+			Entry    watch.Entry
+			Snapshot *storage.Snapshot
+		}{e, snapshot})
+
+	case http.MethodPut:
+		if _, ok := app.requireKey(w, r); !ok {
+			return
+		}
+
+		photos, flights, err := parseWatchQueries(r.URL.Query())
+		if err != nil {
+			app.badRequest(w)
+			return
+		}
+
+		e, err := app.watchlist.Update(reg, photos, flights)
+		if errors.Is(err, watch.ErrNotWatched) {
+			app.notFound(w)
+			return
+		}
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.writeJSON(w, e)
+
+	case http.MethodDelete:
+		if _, ok := app.requireKey(w, r); !ok {
+			return
+		}
+
+		err := app.watchlist.Remove(reg)
+		if errors.Is(err, watch.ErrNotWatched) {
+			app.notFound(w)
+			return
+		}
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		app.clientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (app *application) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	k, ok := app.requireKey(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if k.Admin {
+			app.writeJSON(w, app.webhooks.All())
+			return
+		}
+		app.writeJSON(w, app.webhooks.Owned(k.Name))
+
+	case http.MethodPost:
+		queryParams := r.URL.Query()
+		regs := []string{}
+		if reg := queryParams.Get("reg"); reg != "" {
+			regs = strings.Split(reg, ",")
+		}
+		for _, reg := range regs {
+			if !isAlphanumeric(reg) {
+				app.badRequest(w)
+				return
+			}
+		}
+
+		h, err := app.webhooks.Add(queryParams.Get("url"), regs, k.Name)
+		if err != nil {
+			app.errorJSON(w, http.StatusBadRequest, err, 0)
+			return
+		}
+
+		// the only time the secret is shown
+		app.writeJSONStatus(w, http.StatusCreated, h)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		app.clientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (app *application) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	k, ok := app.requireKey(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	h, err := app.webhooks.Get(id)
+	if errors.Is(err, webhook.ErrNotFound) {
+		app.notFound(w)
+		return
+	}
+	if !k.Admin && h.Owner != k.Name {
+		app.errorJSON(w, http.StatusForbidden, errors.New("webhook belongs to another API key"), 0)
+		return
+	}
+
+	err = app.webhooks.Remove(id)
+	if errors.Is(err, webhook.ErrNotFound) {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	k, ok := app.requireKey(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries := app.dispatcher.Deliveries()
+	if k.Admin {
+		app.writeJSON(w, deliveries)
+		return
+	}
+
+	owned := map[string]bool{}
+	for _, h := range app.webhooks.Owned(k.Name) {
+		owned[h.ID] = true
+	}
+	mine := []webhook.Delivery{}
+	for _, d := range deliveries {
+		if owned[d.HookID] {
+			mine = append(mine, d)
+		}
+	}
+	app.writeJSON(w, mine)
+}
+
+func (app *application) usage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	k := apiKeyFrom(r)
+	if k == nil || !k.Admin {
+		app.errorJSON(w, http.StatusForbidden, errors.New("admin API key required"), 0)
+		return
+	}
+
+	app.writeJSON(w, app.accounts.Usage(time.Now()))
+}
+
+func (app *application) aircraftType(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	t, ok := refdata.LookupType(r.PathValue("code"))
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	app.writeJSON(w, t)
+}
+
+func (app *application) airline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, ok := refdata.LookupAirline(r.PathValue("code"))
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	app.writeJSON(w, a)
+}
+
+func (app *application) image(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isAlphanumeric(id) {
+		app.badRequest(w)
+		return
+	}
+
+	opts, err := parseImageOptions(r.URL.Query())
+	if err != nil {
+		app.badRequest(w)
+		return
+	}
+
+	img, err := app.imageProxy.Get(id, opts)
+	if err != nil {
+		app.logErr(err)
+		app.notFound(w)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s"`, img.Tag)
+	w.Header().Set("ETag", etag)
+	// a JetPhotos photo never changes once uploaded
+	w.Header().Set("Cache-Control", "public, max-age=604800, immutable")
+	w.Header().Set("X-Photo-Credit", fmt.Sprintf("%s / JetPhotos", img.Photographer))
+	w.Header().Set("X-Photo-Source", img.Link)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+	w.Write(img.Data)
 }
 
 func (app *application) aircraftSearch(w http.ResponseWriter, r *http.Request) {
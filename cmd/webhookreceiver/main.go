@@ -0,0 +1,51 @@
+// Command webhookreceiver is a local endpoint for trying out jetapi
+// webhooks. It checks the signature of every delivery and logs it.
+//
+//	go run ./cmd/webhookreceiver -secret <secret from /api/webhooks>
+package main
+
+import (
+	"crypto/hmac"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/macsencasaus/jetapi/internal/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9090", "address to listen on")
+	secret := flag.String("secret", "", "secret of the registered webhook")
+	fail := flag.Bool("fail", false, "answer every delivery with 500 to exercise retries")
+	flag.Parse()
+
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			errorLog.Print(err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		signature := r.Header.Get(webhook.SignatureHeader)
+		if !hmac.Equal([]byte(signature), []byte(webhook.Sign(*secret, body))) {
+			errorLog.Printf("bad signature on delivery %s", r.Header.Get(webhook.DeliveryHeader))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		infoLog.Printf("%s %s (delivery %s): %s", r.Header.Get(webhook.EventHeader),
+			r.Header.Get(webhook.EventIDHeader), r.Header.Get(webhook.DeliveryHeader), body)
+		if *fail {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+
+	infoLog.Printf("Listening for webhooks on %s", *addr)
+	errorLog.Fatal(http.ListenAndServe(*addr, nil))
+}
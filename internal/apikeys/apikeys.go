@@ -0,0 +1,262 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/ratelimit"
+	"github.com/macsencasaus/jetapi/internal/storage"
+)
+
+var (
+	ErrUnknownKey    = errors.New("unknown API key")
+	ErrRateLimited   = errors.New("rate limit exceeded")
+	ErrQuotaExceeded = errors.New("daily quota exceeded")
+)
+
+// name usage without a key is recorded under
+const Anonymous = "anonymous"
+
+const dayLayout = "2006-01-02"
+
+// Key is an API key as written in the keys file.
+type Key struct {
+	Key  string `json:"Key"`
+	Name string `json:"Name"`
+	// requests a minute
+	RateLimit int `json:"RateLimit"`
+	// requests a UTC day, 0 for no quota
+	DailyQuota int  `json:"DailyQuota"`
+	Admin      bool `json:"Admin"`
+}
+
+// Usage counts the requests made with a key.
+type Usage struct {
+	Name          string    `json:"Name"`
+	Day           string    `json:"Day"`
+	Today         int       `json:"Today"`
+	Total         int       `json:"Total"`
+	RateLimited   int       `json:"RateLimited"`
+	QuotaExceeded int       `json:"QuotaExceeded"`
+	LastUsed      time.Time `json:"LastUsed"`
+}
+
+// Limits apply to requests without a key, per client IP.
+type Limits struct {
+	RateLimit  int
+	DailyQuota int
+}
+
+// Accounts checks requests against the limits of their key, and keeps
+// the usage of each key in a JSON file.
+type Accounts struct {
+	usagePath string
+	anonymous Limits
+	keys      map[string]*Key
+
+	mu       sync.Mutex
+	limiters map[string]*ratelimit.Limiter
+	usage    map[string]*Usage
+	// requests today per IP, for the anonymous quota
+	anonDay   string
+	anonToday map[string]int
+	dirty     bool
+}
+
+// Open loads the keys at keysPath, which may be missing, and the usage
+// saved at usagePath.
+func Open(keysPath, usagePath string, anonymous Limits) (*Accounts, error) {
+	a := &Accounts{
+		usagePath: usagePath,
+		anonymous: anonymous,
+		keys:      map[string]*Key{},
+		limiters:  map[string]*ratelimit.Limiter{},
+		usage:     map[string]*Usage{},
+		anonToday: map[string]int{},
+	}
+
+	keys := []*Key{}
+	err := readJSON(keysPath, &keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.Key == "" || k.RateLimit <= 0 || k.DailyQuota < 0 {
+			return nil, fmt.Errorf("key %q needs a Key, a positive RateLimit and a DailyQuota of at least 0", k.Name)
+		}
+		// usage is kept by name
+		if _, ok := a.limiters[k.Name]; ok || k.Name == "" || k.Name == Anonymous {
+			return nil, fmt.Errorf("key name %q is missing or taken", k.Name)
+		}
+		a.keys[k.Key] = k
+		a.limiters[k.Name] = ratelimit.New(k.RateLimit, k.RateLimit)
+	}
+	a.limiters[Anonymous] = ratelimit.New(anonymous.RateLimit, anonymous.RateLimit)
+
+	usage := []*Usage{}
+	err = readJSON(usagePath, &usage)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range usage {
+		a.usage[u.Name] = u
+	}
+	return a, nil
+}
+
+// Lookup returns the key named by key, or nil for an empty key.
+func (a *Accounts) Lookup(key string) (*Key, error) {
+	if key == "" {
+		return nil, nil
+	}
+	k, ok := a.keys[key]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return k, nil
+}
+
+// RateLimit returns the requests a minute allowed to k, or to each
+// anonymous client when k is nil.
+func (a *Accounts) RateLimit(k *Key) int {
+	if k == nil {
+		return a.anonymous.RateLimit
+	}
+	return k.RateLimit
+}
+
+// Take charges cost requests to k, or to the anonymous client ip when
+// k is nil. If they are over a limit it returns ErrRateLimited or
+// ErrQuotaExceeded, and how long until they would be allowed.
+func (a *Accounts) Take(k *Key, ip string, cost int, now time.Time) (time.Duration, error) {
+	name, bucket, quota := Anonymous, ip, a.anonymous.DailyQuota
+	if k != nil {
+		name, bucket, quota = k.Name, k.Name, k.DailyQuota
+	}
+	day := now.UTC().Format(dayLayout)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dirty = true
+
+	u, ok := a.usage[name]
+	if !ok {
+		u = &Usage{Name: name}
+		a.usage[name] = u
+	}
+	if u.Day != day {
+		u.Day = day
+		u.Today = 0
+	}
+	if a.anonDay != day {
+		a.anonDay = day
+		a.anonToday = map[string]int{}
+	}
+
+	used := u.Today
+	if k == nil {
+		used = a.anonToday[ip]
+	}
+	if quota > 0 && used+cost > quota {
+		u.QuotaExceeded++
+		return untilTomorrow(now), ErrQuotaExceeded
+	}
+
+	ok, retry := a.limiters[name].AllowN(bucket, cost, now)
+	if !ok {
+		u.RateLimited++
+		return retry, ErrRateLimited
+	}
+
+	u.Today += cost
+	u.Total += cost
+	u.LastUsed = now
+	if k == nil {
+		a.anonToday[ip] += cost
+	}
+	return 0, nil
+}
+
+// Usage returns the usage of every key that has been used, sorted by
+// name.
+func (a *Accounts) Usage(now time.Time) []Usage {
+	day := now.UTC().Format(dayLayout)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	usage := make([]Usage, 0, len(a.usage))
+	for _, u := range a.usage {
+		cp := *u
+		if cp.Day != day {
+			cp.Day = day
+			cp.Today = 0
+		}
+		usage = append(usage, cp)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Name < usage[j].Name
+	})
+	return usage
+}
+
+// Run saves the usage once every interval while it changes, until ctx
+// is done.
+func (a *Accounts) Run(ctx context.Context, interval time.Duration, errorLog *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := a.save(); err != nil {
+			errorLog.Printf("saving API key usage: %v", err)
+		}
+	}
+}
+
+func (a *Accounts) save() error {
+	a.mu.Lock()
+	if !a.dirty {
+		a.mu.Unlock()
+		return nil
+	}
+	a.dirty = false
+	usage := make([]Usage, 0, len(a.usage))
+	for _, u := range a.usage {
+		usage = append(usage, *u)
+	}
+	a.mu.Unlock()
+
+	return storage.WriteFileAtomic(a.usagePath, usage)
+}
+
+func untilTomorrow(now time.Time) time.Duration {
+	now = now.UTC()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return tomorrow.Sub(now)
+}
+
+// readJSON decodes the file at path into v, leaving v as it is if the
+// file doesn't exist.
+func readJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
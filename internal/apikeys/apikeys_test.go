@@ -0,0 +1,144 @@
+package apikeys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTest(t *testing.T, keys string, anonymous Limits) *Accounts {
+	t.Helper()
+	dir := t.TempDir()
+	keysPath := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(keysPath, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Open(keysPath, filepath.Join(dir, "usage.json"), anonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestOpenRejectsBadKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+	}{
+		{"no key", `[{"Name": "a", "RateLimit": 10}]`},
+		{"no rate limit", `[{"Key": "k", "Name": "a"}]`},
+		{"negative quota", `[{"Key": "k", "Name": "a", "RateLimit": 10, "DailyQuota": -1}]`},
+		{"no name", `[{"Key": "k", "RateLimit": 10}]`},
+		{"anonymous name", `[{"Key": "k", "Name": "anonymous", "RateLimit": 10}]`},
+		{"duplicate name", `[{"Key": "k1", "Name": "a", "RateLimit": 10}, {"Key": "k2", "Name": "a", "RateLimit": 10}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keysPath := filepath.Join(dir, "keys.json")
+			if err := os.WriteFile(keysPath, []byte(tt.keys), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Open(keysPath, filepath.Join(dir, "usage.json"), Limits{RateLimit: 10}); err == nil {
+				t.Error("Open succeeded")
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	a := openTest(t, `[{"Key": "secret", "Name": "a", "RateLimit": 10}]`, Limits{RateLimit: 10})
+
+	tests := []struct {
+		key  string
+		name string
+		err  error
+	}{
+		{"", "", nil},
+		{"secret", "a", nil},
+		{"wrong", "", ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		k, err := a.Lookup(tt.key)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Lookup(%q) returned %v, want %v", tt.key, err, tt.err)
+		}
+		name := ""
+		if k != nil {
+			name = k.Name
+		}
+		if name != tt.name {
+			t.Errorf("Lookup(%q) = %q, want %q", tt.key, name, tt.name)
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	a := openTest(t, `[
+		{"Key": "small", "Name": "small", "RateLimit": 5, "DailyQuota": 8},
+		{"Key": "big", "Name": "big", "RateLimit": 100}
+	]`, Limits{RateLimit: 3, DailyQuota: 4})
+	small, _ := a.Lookup("small")
+	big, _ := a.Lookup("big")
+
+	noon := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		key   *Key
+		ip    string
+		cost  int
+		after time.Duration
+		err   error
+		retry time.Duration
+	}{
+		{"within the rate", small, "1.1.1.1", 5, 0, nil, 0},
+		{"over the rate", small, "1.1.1.1", 1, 0, ErrRateLimited, 12 * time.Second},
+		{"refilled", small, "1.1.1.1", 3, time.Minute, nil, 0},
+		{"over the quota", small, "1.1.1.1", 1, time.Minute, ErrQuotaExceeded, 12*time.Hour - 2*time.Minute},
+		{"quota resets at midnight", small, "1.1.1.1", 5, 12 * time.Hour, nil, 0},
+		{"no quota", big, "1.1.1.1", 100, 0, nil, 0},
+		{"anonymous", nil, "1.1.1.1", 3, 0, nil, 0},
+		{"anonymous over the rate", nil, "1.1.1.1", 1, 0, ErrRateLimited, 20 * time.Second},
+		{"anonymous rate is per IP", nil, "2.2.2.2", 3, 0, nil, 0},
+		{"anonymous over the quota", nil, "1.1.1.1", 2, time.Minute, ErrQuotaExceeded, 0},
+		{"anonymous quota is per IP", nil, "3.3.3.3", 3, 0, nil, 0},
+	}
+
+	now := noon
+	for _, tt := range tests {
+		now = now.Add(tt.after)
+		retry, err := a.Take(tt.key, tt.ip, tt.cost, now)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Take returned %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if tt.retry != 0 && retry != tt.retry {
+			t.Errorf("%s: retry in %v, want %v", tt.name, retry, tt.retry)
+		}
+	}
+
+	usage := map[string]Usage{}
+	for _, u := range a.Usage(now) {
+		usage[u.Name] = u
+	}
+	wantUsage := []struct {
+		name                      string
+		today, total, rate, quota int
+	}{
+		{"anonymous", 9, 9, 1, 1},
+		{"big", 100, 100, 0, 0},
+		{"small", 5, 13, 1, 1},
+	}
+	for _, tt := range wantUsage {
+		u := usage[tt.name]
+		if u.Today != tt.today || u.Total != tt.total || u.RateLimited != tt.rate || u.QuotaExceeded != tt.quota {
+			t.Errorf("usage of %s = %+v, want Today %d, Total %d, RateLimited %d, QuotaExceeded %d",
+				tt.name, u, tt.today, tt.total, tt.rate, tt.quota)
+		}
+	}
+}
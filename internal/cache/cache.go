@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps values for a fixed time after they are loaded. Callers
+// asking for a key that is being loaded wait for that load instead of
+// starting their own.
+type Cache[V any] struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry[V]
+}
+
+type entry[V any] struct {
+	value   V
+	err     error
+	expires time.Time
+	// closed once the value is loaded
+	ready chan struct{}
+}
+
+func New[V any](ttl time.Duration) *Cache[V] {
+	return &Cache[V]{ttl: ttl, entries: map[string]*entry[V]{}}
+}
+
+// Get returns the cached value of key, calling load if it is missing
+// or expired. Errors are not cached.
+func (c *Cache[V]) Get(key string, load func() (V, error)) (V, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		select {
+		case <-e.ready:
+			if now.After(e.expires) || e.err != nil {
+				ok = false
+			}
+		default:
+			// still loading
+		}
+	}
+	if !ok {
+		e = &entry[V]{ready: make(chan struct{})}
+		c.entries[key] = e
+		c.evictExpired(now)
+		c.mu.Unlock()
+
+		e.value, e.err = load()
+		e.expires = time.Now().Add(c.ttl)
+		close(e.ready)
+
+		if e.err != nil {
+			c.mu.Lock()
+			if c.entries[key] == e {
+				delete(c.entries, key)
+			}
+			c.mu.Unlock()
+		}
+		return e.value, e.err
+	}
+	c.mu.Unlock()
+
+	<-e.ready
+	return e.value, e.err
+}
+
+// evictExpired drops loaded entries past their expiry, so keys that
+// are never asked for again don't stay around.
+func (c *Cache[V]) evictExpired(now time.Time) {
+	for key, e := range c.entries {
+		select {
+		case <-e.ready:
+			if now.After(e.expires) {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+}
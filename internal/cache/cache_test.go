@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	errLoad := errors.New("load failed")
+
+	type get struct {
+		key   string
+		value int
+		err   error
+		// whether load should be called
+		loads bool
+	}
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		gets []get
+	}{
+		{
+			name: "cached",
+			ttl:  time.Hour,
+			gets: []get{
+				{"a", 1, nil, true},
+				{"a", 1, nil, false},
+				{"b", 2, nil, true},
+			},
+		},
+		{
+			name: "expired",
+			ttl:  -time.Second,
+			gets: []get{
+				{"a", 1, nil, true},
+				{"a", 2, nil, true},
+			},
+		},
+		{
+			name: "errors are not cached",
+			ttl:  time.Hour,
+			gets: []get{
+				{"a", 0, errLoad, true},
+				{"a", 1, nil, true},
+				{"a", 1, nil, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[int](tt.ttl)
+			for i, g := range tt.gets {
+				loaded := false
+				v, err := c.Get(g.key, func() (int, error) {
+					loaded = true
+					return g.value, g.err
+				})
+				if loaded != g.loads {
+					t.Errorf("get %d: loaded = %v, want %v", i, loaded, g.loads)
+				}
+				if v != g.value || !errors.Is(err, g.err) {
+					t.Errorf("get %d = %d, %v, want %d, %v", i, v, err, g.value, g.err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSharesLoads(t *testing.T) {
+	c := New[int](time.Hour)
+	release := make(chan struct{})
+	var loads atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.Get("a", func() (int, error) {
+				loads.Add(1)
+				<-release
+				return 7, nil
+			})
+			if v != 7 || err != nil {
+				t.Errorf("Get = %d, %v", v, err)
+			}
+		}()
+	}
+
+	// let the callers reach Get before the load finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+}
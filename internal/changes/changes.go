@@ -0,0 +1,128 @@
+package changes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+	"github.com/macsencasaus/jetapi/internal/storage"
+)
+
+type EventType string
+
+const (
+	PhotoAdded          EventType = "photo.added"
+	FlightAdded         EventType = "flight.added"
+	FlightStatusChanged EventType = "flight.status_changed"
+	OperatorChanged     EventType = "operator.changed"
+)
+
+type Event struct {
+	// the same change always gets the same ID, so receivers can
+	// drop duplicates
+	ID   string    `json:"ID"`
+	Type EventType `json:"Type"`
+	Reg  string    `json:"Reg"`
+	Time time.Time `json:"Time"`
+
+	Photo  *sites.ImageAttributes  `json:"Photo,omitempty"`
+	Flight *sites.FlightAttributes `json:"Flight,omitempty"`
+	Old    string                  `json:"Old,omitempty"`
+	New    string                  `json:"New,omitempty"`
+}
+
+// Diff compares two successive snapshots of an aircraft. Sources
+// missing from either snapshot are not compared.
+func Diff(prev, next *storage.Snapshot) []Event {
+	events := []Event{}
+	if prev == nil || next == nil {
+		return events
+	}
+
+	newEvent := func(t EventType, key string) Event {
+		return Event{
+			ID:   eventID(next.Reg, t, key),
+			Type: t,
+			Reg:  next.Reg,
+			Time: next.Time,
+		}
+	}
+
+	if prev.JetPhotos != nil && next.JetPhotos != nil {
+		known := map[string]bool{}
+		for _, image := range prev.JetPhotos.Images {
+			known[image.Link] = true
+		}
+		// photos are newest first, so anything after a known photo is
+		// only new to us because more photos were asked for
+		for _, image := range next.JetPhotos.Images {
+			if known[image.Link] {
+				break
+			}
+			e := newEvent(PhotoAdded, image.Link)
+			e.Photo = &image
+			events = append(events, e)
+		}
+	}
+
+	if prev.FlightRadar != nil && next.FlightRadar != nil {
+		events = append(events, diffFlightRadar(prev.FlightRadar, next.FlightRadar, newEvent)...)
+	}
+
+	return events
+}
+
+func diffFlightRadar(
+	prev, next *sites.FlightRadarResult,
+	newEvent func(EventType, string) Event,
+) []Event {
+	events := []Event{}
+
+	if prev.Operator != "" && next.Operator != "" && prev.Operator != next.Operator {
+		e := newEvent(OperatorChanged, prev.Operator+"|"+next.Operator)
+		e.Old = prev.Operator
+		e.New = next.Operator
+		events = append(events, e)
+	}
+
+	known := map[string]*sites.FlightAttributes{}
+	for _, f := range prev.Flights {
+		known[flightKey(f)] = f
+	}
+
+	seenKnown := false
+	for _, f := range next.Flights {
+		key := flightKey(f)
+		old, ok := known[key]
+		if !ok {
+			// flights are newest first, like photos
+			if !seenKnown {
+				e := newEvent(FlightAdded, key)
+				e.Flight = f
+				events = append(events, e)
+			}
+			continue
+		}
+		seenKnown = true
+
+		if old.Status != f.Status {
+			e := newEvent(FlightStatusChanged, key+"|"+f.Status)
+			e.Flight = f
+			e.Old = old.Status
+			e.New = f.Status
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+func flightKey(f *sites.FlightAttributes) string {
+	return f.Date + "|" + f.Flight
+}
+
+func eventID(reg string, t EventType, key string) string {
+	sum := sha256.Sum256([]byte(reg + "|" + string(t) + "|" + key))
+	return hex.EncodeToString(sum[:8])
+}
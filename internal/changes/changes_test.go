@@ -0,0 +1,117 @@
+package changes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+	"github.com/macsencasaus/jetapi/internal/storage"
+)
+
+func snapshot(photos []string, operator string, flights ...*sites.FlightAttributes) *storage.Snapshot {
+	jp := &sites.JetPhotosResult{Reg: "G-XLEA"}
+	for _, link := range photos {
+		jp.Images = append(jp.Images, sites.ImageAttributes{Link: link})
+	}
+	return &storage.Snapshot{
+		Reg:         "G-XLEA",
+		Time:        time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		JetPhotos:   jp,
+		FlightRadar: &sites.FlightRadarResult{Operator: operator, Flights: flights},
+	}
+}
+
+func flight(date, number, status string) *sites.FlightAttributes {
+	return &sites.FlightAttributes{Date: date, Flight: number, Status: status}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		prev *storage.Snapshot
+		next *storage.Snapshot
+		want []EventType
+	}{
+		{
+			name: "no previous snapshot",
+			prev: nil,
+			next: snapshot([]string{"p2", "p1"}, "BA"),
+			want: nil,
+		},
+		{
+			name: "unchanged",
+			prev: snapshot([]string{"p2", "p1"}, "BA", flight("01 May 2024", "BA1", "Landed")),
+			next: snapshot([]string{"p2", "p1"}, "BA", flight("01 May 2024", "BA1", "Landed")),
+			want: nil,
+		},
+		{
+			name: "new photo",
+			prev: snapshot([]string{"p2", "p1"}, "BA"),
+			next: snapshot([]string{"p3", "p2", "p1"}, "BA"),
+			want: []EventType{PhotoAdded},
+		},
+		{
+			name: "more photos asked for",
+			prev: snapshot([]string{"p3"}, "BA"),
+			next: snapshot([]string{"p3", "p2", "p1"}, "BA"),
+			want: nil,
+		},
+		{
+			name: "fewer photos asked for",
+			prev: snapshot([]string{"p3", "p2", "p1"}, "BA"),
+			next: snapshot([]string{"p3"}, "BA"),
+			want: nil,
+		},
+		{
+			name: "operator changed",
+			prev: snapshot(nil, "BA"),
+			next: snapshot(nil, "VS"),
+			want: []EventType{OperatorChanged},
+		},
+		{
+			name: "new flight and status change",
+			prev: snapshot(nil, "BA",
+				flight("01 May 2024", "BA1", "Scheduled")),
+			next: snapshot(nil, "BA",
+				flight("02 May 2024", "BA2", "Scheduled"),
+				flight("01 May 2024", "BA1", "Landed")),
+			want: []EventType{FlightAdded, FlightStatusChanged},
+		},
+		{
+			name: "older flights past the known ones",
+			prev: snapshot(nil, "BA",
+				flight("02 May 2024", "BA2", "Landed")),
+			next: snapshot(nil, "BA",
+				flight("02 May 2024", "BA2", "Landed"),
+				flight("01 May 2024", "BA1", "Landed")),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := Diff(tt.prev, tt.next)
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d events %+v, want %v", len(events), events, tt.want)
+			}
+			for i, e := range events {
+				if e.Type != tt.want[i] {
+					t.Errorf("event %d is %s, want %s", i, e.Type, tt.want[i])
+				}
+				if e.Reg != "G-XLEA" || e.ID == "" {
+					t.Errorf("event %d has Reg %q and ID %q", i, e.Reg, e.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffIDsAreStable(t *testing.T) {
+	prev := snapshot([]string{"p1"}, "BA")
+	next := snapshot([]string{"p2", "p1"}, "BA")
+
+	first, second := Diff(prev, next), Diff(prev, next)
+	if len(first) != 1 || first[0].ID != second[0].ID {
+		t.Fatalf("IDs differ between diffs: %+v, %+v", first, second)
+	}
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+// largest single image read into an archive
+const maxArchiveImageBytes = 20 << 20
+
+// archiveImage is one photo of metadata.json, with the name of its
+// file in the archive, empty if it was left out.
+type archiveImage struct {
+	File string `json:"File"`
+	sites.ImageAttributes
+}
+
+type archiveMetadata struct {
+	Reg     string         `json:"Reg"`
+	Created time.Time      `json:"Created"`
+	Images  []archiveImage `json:"Images"`
+}
+
+// WriteArchive streams a ZIP of the full-size photos of jp to w, with
+// metadata.json and CREDITS.txt. Each photo is read whole before it is
+// added, so photos that would take the archive's images past maxBytes,
+// or can't be fetched, are listed in the metadata without a file.
+func WriteArchive(w io.Writer, jp *sites.JetPhotosResult, maxBytes int64, now time.Time) error {
+	zw := zip.NewWriter(w)
+
+	meta := archiveMetadata{Reg: jp.Reg, Created: now.UTC()}
+	credits := &strings.Builder{}
+	fmt.Fprintf(credits, "Photos of %s from JetPhotos, %s.\n", jp.Reg, now.UTC().Format("2006-01-02"))
+	fmt.Fprintf(credits, "Each photo is the work of its photographer. Please credit them when using it.\n\n")
+
+	var total int64
+	for i, image := range jp.Images {
+		entry := archiveImage{ImageAttributes: image}
+
+		data, ext, err := fetchArchiveImage(image.Image)
+		if err == nil && total+int64(len(data)) <= maxBytes {
+			entry.File = fmt.Sprintf("images/%03d-%s%s", i+1, image.ID, ext)
+			err = writeZipFile(zw, entry.File, zip.Store, data, now)
+			if err != nil {
+				return err
+			}
+			total += int64(len(data))
+
+			fmt.Fprintf(credits, "%s\n  Photo: %s\n  %s\n",
+				path.Base(entry.File), image.Photographer, image.Link)
+		}
+
+		meta.Images = append(meta.Images, entry)
+	}
+
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeZipFile(zw, "metadata.json", zip.Deflate, metaJSON, now)
+	if err != nil {
+		return err
+	}
+
+	err = writeZipFile(zw, "CREDITS.txt", zip.Deflate, []byte(credits.String()), now)
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func fetchArchiveImage(URL string) ([]byte, string, error) {
+	if URL == "" {
+		return nil, "", fmt.Errorf("no image URL")
+	}
+
+	body, ctype, err := scraper.FetchImage(URL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxArchiveImageBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxArchiveImageBytes {
+		return nil, "", fmt.Errorf("image too large")
+	}
+
+	ext := ".jpg"
+	if strings.HasPrefix(ctype, "image/png") {
+		ext = ".png"
+	}
+	return data, ext, nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, method uint16, data []byte, now time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: now,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
@@ -0,0 +1,206 @@
+package export
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/refdata"
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+const (
+	icsTimeLayout = "20060102T150405Z"
+	// local time wherever the calendar is viewed, RFC 5545 3.3.5
+	icsFloatingLayout = "20060102T150405"
+	// how often subscribed calendars are asked to refresh
+	icsRefresh = "PT1H"
+	// octets per line before folding, RFC 5545 3.1
+	icsLineLength = 75
+)
+
+// WriteCalendar writes an iCalendar of the flights of reg, one event
+// per flight with a parseable date and departure time. Times are
+// converted from the local time of each airport to UTC. Flights from
+// an airport missing from refdata get floating times instead, its
+// local clock time without a zone.
+func WriteCalendar(w io.Writer, reg string, flights []*sites.FlightAttributes, now time.Time) error {
+	cw := &icsWriter{w: w}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//JetAPI//Flights//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + icsEscape(reg+" flights"))
+	cw.line("REFRESH-INTERVAL;VALUE=DURATION:" + icsRefresh)
+	cw.line("X-PUBLISHED-TTL:" + icsRefresh)
+
+	for _, f := range flights {
+		start, end, floating, ok := flightTimes(f)
+		if !ok {
+			continue
+		}
+
+		from, to := airportCode(f.From), airportCode(f.To)
+		summary := fmt.Sprintf("%s %s-%s", f.Flight, from, to)
+
+		description := []string{
+			"Aircraft: " + reg,
+			"Status: " + f.Status,
+			"STD: " + f.STD,
+			"ATD: " + f.ATD,
+			"STA: " + f.STA,
+			"Flight time: " + f.FlightTime,
+		}
+
+		cw.line("BEGIN:VEVENT")
+		cw.line("UID:" + flightUID(reg, f))
+		cw.line("DTSTAMP:" + now.UTC().Format(icsTimeLayout))
+		cw.line("DTSTART:" + icsTime(start, floating))
+		cw.line("DTEND:" + icsTime(end, floating))
+		cw.line("SUMMARY:" + icsEscape(summary))
+		cw.line("LOCATION:" + icsEscape(f.From))
+		cw.line("DESCRIPTION:" + icsEscape(strings.Join(description, "\n")))
+		if strings.Contains(strings.ToLower(f.Status), "cancel") {
+			cw.line("STATUS:CANCELLED")
+		} else {
+			cw.line("STATUS:CONFIRMED")
+		}
+		cw.line("END:VEVENT")
+	}
+
+	cw.line("END:VCALENDAR")
+	return cw.err
+}
+
+// flightUID identifies a flight the way the flight log does, by date
+// and flight number, so a refreshed calendar replaces its events
+// instead of duplicating them.
+func flightUID(reg string, f *sites.FlightAttributes) string {
+	sum := sha1.Sum([]byte(strings.ToUpper(reg) + "|" + f.Date + "|" + f.Flight))
+	return hex.EncodeToString(sum[:]) + "@jetapi"
+}
+
+// flightTimes returns the scheduled departure and arrival of f. FR24
+// shows them in the local time of each airport. Without an arrival
+// time the flight time, or else an hour, is added to the departure.
+// floating is set when the departure airport is unknown, leaving the
+// times on its clock rather than in UTC.
+func flightTimes(f *sites.FlightAttributes) (start, end time.Time, floating, ok bool) {
+	date, ok := sites.ParseJPDate(f.Date)
+	if !ok {
+		return time.Time{}, time.Time{}, false, false
+	}
+
+	// a floating time keeps its clock in UTC, which is never converted
+	from, fromOK := refdata.LookupAirport(f.From)
+	loc := time.UTC
+	if fromOK {
+		loc = from.Location()
+	}
+	start, ok = clockTime(date, f.STD, loc)
+	if !ok {
+		return time.Time{}, time.Time{}, false, false
+	}
+
+	// an arrival clock can only be compared with a known departure
+	to, toOK := refdata.LookupAirport(f.To)
+	endOK := false
+	if fromOK && toOK {
+		end, endOK = clockTime(date, f.STA, to.Location())
+	}
+	if !endOK {
+		d, err := parseFlightTime(f.FlightTime)
+		if err != nil {
+			d = time.Hour
+		}
+		return start, start.Add(d), !fromOK, true
+	}
+
+	// arrivals past midnight are shown with the departure date
+	for !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, false, true
+}
+
+// clockTime returns the clock time clock on date in loc.
+func clockTime(date time.Time, clock string, loc *time.Location) (time.Time, bool) {
+	clock = strings.TrimSpace(clock)
+	for _, layout := range []string{"15:04", "3:04 PM", "3:04PM"} {
+		t, err := time.Parse(layout, clock)
+		if err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(),
+				t.Hour(), t.Minute(), 0, 0, loc), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func icsTime(t time.Time, floating bool) string {
+	if floating {
+		return t.Format(icsFloatingLayout)
+	}
+	return t.UTC().Format(icsTimeLayout)
+}
+
+// parseFlightTime parses FR24 durations such as "1:25".
+func parseFlightTime(s string) (time.Duration, error) {
+	var h, m int
+	_, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// airportCode returns the code of FR24 names such as "London (LHR)".
+func airportCode(name string) string {
+	if a, ok := refdata.LookupAirport(name); ok {
+		return a.IATA
+	}
+	return strings.TrimSpace(name)
+}
+
+func icsEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// icsWriter writes content lines, folding long ones, and keeps the
+// first error.
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *icsWriter) line(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > icsLineLength {
+			// continuation lines start with a space, which counts
+			// towards their length
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+
+	_, cw.err = io.WriteString(cw.w, b.String())
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+func TestICSLine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"short", "BEGIN:VEVENT", "BEGIN:VEVENT\r\n"},
+		{"exactly 75", strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{"76", strings.Repeat("a", 76), strings.Repeat("a", 75) + "\r\n a\r\n"},
+		{
+			"several folds",
+			strings.Repeat("a", 75+74+3),
+			strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n aaa\r\n",
+		},
+		{
+			// a two octet rune doesn't fit in the last octet
+			"multibyte rune",
+			strings.Repeat("a", 74) + "é",
+			strings.Repeat("a", 74) + "\r\n é\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			cw := &icsWriter{w: &b}
+			cw.line(tt.in)
+			if b.String() != tt.want {
+				t.Errorf("got %q, want %q", b.String(), tt.want)
+			}
+			for _, l := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+				if len(l) > icsLineLength {
+					t.Errorf("line of %d octets: %q", len(l), l)
+				}
+			}
+		})
+	}
+}
+
+func TestFlightTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		f        sites.FlightAttributes
+		start    string
+		end      string
+		floating bool
+		ok       bool
+	}{
+		{
+			name:  "known airports",
+			f:     sites.FlightAttributes{Date: "01 Jul 2024", From: "London (LHR)", To: "New York (JFK)", STD: "10:00", STA: "13:00"},
+			start: "20240701T090000Z",
+			end:   "20240701T170000Z",
+			ok:    true,
+		},
+		{
+			name:  "arrival past midnight",
+			f:     sites.FlightAttributes{Date: "01 Jul 2024", From: "JFK", To: "LHR", STD: "22:00", STA: "10:00"},
+			start: "20240702T020000Z",
+			end:   "20240702T090000Z",
+			ok:    true,
+		},
+		{
+			name:  "unknown arrival uses flight time",
+			f:     sites.FlightAttributes{Date: "01 Jan 2024", From: "LHR", To: "Nowhere", STD: "10:00", FlightTime: "1:25"},
+			start: "20240101T100000Z",
+			end:   "20240101T112500Z",
+			ok:    true,
+		},
+		{
+			name:     "unknown departure floats",
+			f:        sites.FlightAttributes{Date: "01 Jul 2024", From: "Nowhere", To: "LHR", STD: "10:00", STA: "11:00"},
+			start:    "20240701T100000",
+			end:      "20240701T110000",
+			floating: true,
+			ok:       true,
+		},
+		{
+			name: "no departure time",
+			f:    sites.FlightAttributes{Date: "01 Jul 2024", From: "LHR", To: "JFK", STD: "—"},
+		},
+		{
+			name: "no date",
+			f:    sites.FlightAttributes{Date: "", From: "LHR", To: "JFK", STD: "10:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, floating, ok := flightTimes(&tt.f)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if floating != tt.floating {
+				t.Errorf("floating = %v, want %v", floating, tt.floating)
+			}
+			if got := icsTime(start, floating); got != tt.start {
+				t.Errorf("start = %s, want %s", got, tt.start)
+			}
+			if got := icsTime(end, floating); got != tt.end {
+				t.Errorf("end = %s, want %s", got, tt.end)
+			}
+		})
+	}
+}
+
+func TestWriteCalendarEscapes(t *testing.T) {
+	var b strings.Builder
+	flights := []*sites.FlightAttributes{{
+		Date: "01 Jul 2024", Flight: "BA1", From: "LHR", To: "JFK",
+		STD: "10:00", STA: "13:00", Status: "Cancelled; rebooked, sorry",
+	}}
+	err := WriteCalendar(&b, "G-XLEA", flights, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	for _, want := range []string{
+		`Status: Cancelled\; rebooked\, sorry`,
+		"STATUS:CANCELLED\r\n",
+		"SUMMARY:BA1 LHR-JFK\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar is missing %q:\n%s", want, unfolded)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Published string      `xml:"published,omitempty"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Links     []atomLink  `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate,omitempty"`
+	Creator     string       `xml:"dc:creator,omitempty"`
+	Description string       `xml:"description"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// WriteAtom writes an Atom feed of the photos of jp, newest upload
+// first as JetPhotos lists them. base is the scheme and host the feed
+// is served from, and self its URL.
+func WriteAtom(w io.Writer, jp *sites.JetPhotosResult, base, self string, now time.Time) error {
+	feed := atomFeed{
+		Title: fmt.Sprintf("JetPhotos of %s", jp.Reg),
+		// the same for every URL of the feed of a registration
+		ID:      fmt.Sprintf("%s/feeds/photos/%s", base, jp.Reg),
+		Updated: feedUpdated(jp, now).Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+		},
+	}
+
+	for _, image := range jp.Images {
+		updated := now.UTC()
+		published, ok := sites.ParseJPDate(image.DateUploaded)
+		if ok {
+			updated = published
+		}
+
+		entry := atomEntry{
+			Title:   photoTitle(image),
+			ID:      image.Link,
+			Updated: updated.Format(time.RFC3339),
+			Author:  atomAuthor{Name: image.Photographer, URI: absoluteURL(base, image.PhotographerLink)},
+			Links: []atomLink{
+				{Rel: "alternate", Type: "text/html", Href: image.Link},
+				{Rel: "enclosure", Type: "image/jpeg", Href: image.Thumbnail},
+			},
+			Content: atomContent{Type: "html", Body: photoHTML(image)},
+		}
+		if ok {
+			entry.Published = published.Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return writeXML(w, feed)
+}
+
+// WriteRSS writes the photos of jp as an RSS 2.0 feed. link is the URL
+// of the feed.
+func WriteRSS(w io.Writer, jp *sites.JetPhotosResult, link string, now time.Time) error {
+	feed := rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         fmt.Sprintf("JetPhotos of %s", jp.Reg),
+			Link:          link,
+			Description:   fmt.Sprintf("Newest JetPhotos uploads of %s", jp.Reg),
+			LastBuildDate: feedUpdated(jp, now).Format(time.RFC1123Z),
+		},
+	}
+
+	for _, image := range jp.Images {
+		item := rssItem{
+			Title:       photoTitle(image),
+			Link:        image.Link,
+			GUID:        rssGUID{IsPermaLink: true, ID: image.Link},
+			Creator:     image.Photographer,
+			Description: photoHTML(image),
+			// JetPhotos doesn't give the size of the thumbnail
+			Enclosure: rssEnclosure{URL: image.Thumbnail, Length: 0, Type: "image/jpeg"},
+		}
+		if published, ok := sites.ParseJPDate(image.DateUploaded); ok {
+			item.PubDate = published.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	return writeXML(w, feed)
+}
+
+// absoluteURL resolves the path of a link of this API against base,
+// as Atom URIs can't be relative.
+func absoluteURL(base, link string) string {
+	if !strings.HasPrefix(link, "/") {
+		return link
+	}
+	return base + link
+}
+
+// feedUpdated returns the newest upload date of jp, or now if none
+// can be parsed.
+func feedUpdated(jp *sites.JetPhotosResult, now time.Time) time.Time {
+	var newest time.Time
+	for _, image := range jp.Images {
+		t, ok := sites.ParseJPDate(image.DateUploaded)
+		if ok && t.After(newest) {
+			newest = t
+		}
+	}
+	if newest.IsZero() {
+		return now.UTC()
+	}
+	return newest
+}
+
+func photoTitle(image sites.ImageAttributes) string {
+	title := image.Reg
+	if image.Aircraft != "" {
+		title += " " + image.Aircraft
+	}
+	if image.Location != "" {
+		title += " at " + image.Location
+	}
+	return title
+}
+
+func photoHTML(image sites.ImageAttributes) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<p><a href="%s"><img src="%s" alt="%s"></a></p>`,
+		html.EscapeString(image.Link),
+		html.EscapeString(image.Thumbnail),
+		html.EscapeString(image.Reg),
+	)
+
+	details := []string{}
+	for _, d := range []string{image.Airline, image.Aircraft, image.Location} {
+		if d != "" {
+			details = append(details, html.EscapeString(d))
+		}
+	}
+	if image.DateTaken != "" {
+		details = append(details, "taken "+html.EscapeString(image.DateTaken))
+	}
+	if image.Photographer != "" {
+		details = append(details, "by "+html.EscapeString(image.Photographer))
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(&b, "<p>%s</p>", strings.Join(details, ", "))
+	}
+	return b.String()
+}
+
+func writeXML(w io.Writer, v any) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err = enc.Encode(v); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
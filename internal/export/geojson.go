@@ -0,0 +1,165 @@
+package export
+
+import (
+	"math"
+
+	"github.com/macsencasaus/jetapi/internal/refdata"
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+// points per great-circle route
+const routeSegments = 64
+
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+	// flights left out for an airport missing from refdata, so
+	// clients can tell the collection is partial
+	Skipped int `json:"Skipped"`
+}
+
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// position is [longitude, latitude], as GeoJSON orders them
+type position [2]float64
+
+// FlightsGeoJSON returns a LineString along the great circle of each
+// flight with a known origin and destination, followed by a Point for
+// every airport those flights use. Flights with an airport missing
+// from refdata are left out and counted in Skipped.
+func FlightsGeoJSON(flights []*sites.FlightLeg) *FeatureCollection {
+	fc := &FeatureCollection{Type: "FeatureCollection", Features: []*Feature{}}
+
+	seen := map[*refdata.Airport]bool{}
+	used := []*refdata.Airport{}
+	for _, f := range flights {
+		from, fromOK := refdata.LookupAirport(f.From)
+		to, toOK := refdata.LookupAirport(f.To)
+		if !fromOK || !toOK {
+			fc.Skipped++
+			continue
+		}
+
+		fc.Features = append(fc.Features, &Feature{
+			Type:     "Feature",
+			Geometry: routeGeometry(from, to),
+			Properties: map[string]any{
+				"Reg":    f.Reg,
+				"Flight": f.Flight,
+				"Date":   f.Date,
+				"From":   from.IATA,
+				"To":     to.IATA,
+				"STD":    f.STD,
+				"STA":    f.STA,
+				"Status": f.Status,
+			},
+		})
+
+		for _, a := range []*refdata.Airport{from, to} {
+			if !seen[a] {
+				seen[a] = true
+				used = append(used, a)
+			}
+		}
+	}
+
+	for _, a := range used {
+		fc.Features = append(fc.Features, &Feature{
+			Type: "Feature",
+			Geometry: &Geometry{
+				Type:        "Point",
+				Coordinates: round(position{a.Longitude, a.Latitude}),
+			},
+			Properties: map[string]any{
+				"IATA": a.IATA,
+				"ICAO": a.ICAO,
+				"Name": a.Name,
+			},
+		})
+	}
+	return fc
+}
+
+// routeGeometry returns the great circle from a to b, split into a
+// MultiLineString where it crosses the antimeridian.
+func routeGeometry(a, b *refdata.Airport) *Geometry {
+	points := greatCircle(a.Latitude, a.Longitude, b.Latitude, b.Longitude, routeSegments)
+
+	lines := [][]position{{points[0]}}
+	for _, p := range points[1:] {
+		line := &lines[len(lines)-1]
+		prev := (*line)[len(*line)-1]
+		if math.Abs(p[0]-prev[0]) <= 180 {
+			*line = append(*line, p)
+			continue
+		}
+
+		// end the line at the antimeridian and start the next on the
+		// other side, at the latitude where the segment crosses it
+		edge := math.Copysign(180, prev[0])
+		next := p[0] + 2*edge
+		lat := prev[1] + (p[1]-prev[1])*(edge-prev[0])/(next-prev[0])
+		*line = append(*line, round(position{edge, lat}))
+		lines = append(lines, []position{round(position{-edge, lat}), p})
+	}
+
+	if len(lines) == 1 {
+		return &Geometry{Type: "LineString", Coordinates: lines[0]}
+	}
+	return &Geometry{Type: "MultiLineString", Coordinates: lines}
+}
+
+// greatCircle returns n+1 points from (lat1, lon1) to (lat2, lon2)
+// along the great circle between them, in degrees.
+func greatCircle(lat1, lon1, lat2, lon2 float64, n int) []position {
+	phi1, lam1 := radians(lat1), radians(lon1)
+	phi2, lam2 := radians(lat2), radians(lon2)
+
+	// angular distance, by the haversine formula
+	d := 2 * math.Asin(math.Sqrt(
+		math.Pow(math.Sin((phi2-phi1)/2), 2)+
+			math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin((lam2-lam1)/2), 2),
+	))
+	// the same or antipodal points have no single great circle between
+	// them, so the route is a straight segment
+	if math.Abs(math.Sin(d)) < 1e-9 {
+		return []position{round(position{lon1, lat1}), round(position{lon2, lat2})}
+	}
+
+	points := make([]position, 0, n+1)
+	for i := 0; i <= n; i++ {
+		f := float64(i) / float64(n)
+		a := math.Sin((1-f)*d) / math.Sin(d)
+		b := math.Sin(f*d) / math.Sin(d)
+
+		x := a*math.Cos(phi1)*math.Cos(lam1) + b*math.Cos(phi2)*math.Cos(lam2)
+		y := a*math.Cos(phi1)*math.Sin(lam1) + b*math.Cos(phi2)*math.Sin(lam2)
+		z := a*math.Sin(phi1) + b*math.Sin(phi2)
+
+		phi := math.Atan2(z, math.Sqrt(x*x+y*y))
+		lam := math.Atan2(y, x)
+		points = append(points, round(position{degrees(lam), degrees(phi)}))
+	}
+	return points
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// round keeps 5 decimal places, about a metre
+func round(p position) position {
+	for i := range p {
+		p[i] = math.Round(p[i]*1e5) / 1e5
+	}
+	return p
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"math"
+	"testing"
+
+	"github.com/macsencasaus/jetapi/internal/refdata"
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+func TestGreatCircle(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		// the point halfway along
+		mid position
+	}{
+		{"along the equator", 0, 0, 0, 90, position{45, 0}},
+		{"along a meridian", 0, 10, 60, 10, position{10, 30}},
+		{"westwards", 0, 0, 0, -90, position{-45, 0}},
+		{"towards the pole", 0, 0, 90, 0, position{0, 45}},
+		{"same point", 51.47, -0.46, 51.47, -0.46, position{-0.46, 51.47}},
+		{"antipodal", 0, 0, 0, 180, position{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := greatCircle(tt.lat1, tt.lon1, tt.lat2, tt.lon2, 2)
+			for _, p := range points {
+				if math.IsNaN(p[0]) || math.IsNaN(p[1]) {
+					t.Fatalf("route has point %v", p)
+				}
+			}
+			first, last := points[0], points[len(points)-1]
+			if !near(first, position{tt.lon1, tt.lat1}) || !near(last, position{tt.lon2, tt.lat2}) {
+				t.Errorf("route runs from %v to %v", first, last)
+			}
+			if len(points) == 3 && !near(points[1], tt.mid) {
+				t.Errorf("midpoint is %v, want %v", points[1], tt.mid)
+			}
+		})
+	}
+}
+
+func TestRouteGeometry(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"LHR", "JFK", "LineString"},
+		{"SFO", "NRT", "MultiLineString"},
+		{"NRT", "SFO", "MultiLineString"},
+	}
+
+	for _, tt := range tests {
+		from, _ := refdata.LookupAirport(tt.from)
+		to, _ := refdata.LookupAirport(tt.to)
+		g := routeGeometry(from, to)
+		if g.Type != tt.want {
+			t.Errorf("%s-%s is a %s, want %s", tt.from, tt.to, g.Type, tt.want)
+			continue
+		}
+		if g.Type != "MultiLineString" {
+			continue
+		}
+
+		lines := g.Coordinates.([][]position)
+		for i, line := range lines {
+			for j := 1; j < len(line); j++ {
+				if math.Abs(line[j][0]-line[j-1][0]) > 180 {
+					t.Errorf("%s-%s line %d jumps from %v to %v", tt.from, tt.to, i, line[j-1], line[j])
+				}
+			}
+		}
+		end, start := lines[0][len(lines[0])-1], lines[1][0]
+		if math.Abs(end[0]) != 180 || end[0] != -start[0] || end[1] != start[1] {
+			t.Errorf("%s-%s is split at %v and %v", tt.from, tt.to, end, start)
+		}
+	}
+}
+
+func TestFlightsGeoJSON(t *testing.T) {
+	flights := []*sites.FlightLeg{
+		{FlightAttributes: sites.FlightAttributes{Flight: "BA1", From: "LHR", To: "JFK"}},
+		{FlightAttributes: sites.FlightAttributes{Flight: "BA2", From: "JFK", To: "LHR"}},
+		{FlightAttributes: sites.FlightAttributes{Flight: "XX1", From: "LHR", To: "Nowhere"}},
+	}
+
+	fc := FlightsGeoJSON(flights)
+	// two routes and two airports
+	if len(fc.Features) != 4 || fc.Skipped != 1 {
+		t.Errorf("got %d features and %d skipped, want 4 and 1", len(fc.Features), fc.Skipped)
+	}
+}
+
+func near(a, b position) bool {
+	return math.Abs(a[0]-b[0]) < 1e-4 && math.Abs(a[1]-b[1]) < 1e-4
+}
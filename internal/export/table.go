@@ -0,0 +1,99 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+type Table string
+
+const (
+	TableFlights  Table = "flights"
+	TablePhotos   Table = "photos"
+	TableAircraft Table = "aircraft"
+)
+
+var (
+	flightsHeader = []string{
+		"Reg", "Date", "Flight", "From", "To",
+		"FlightTime", "STD", "ATD", "STA", "Status",
+	}
+	photosHeader = []string{
+		"Reg", "ID", "Link", "Image", "Thumbnail", "DateTaken", "DateUploaded",
+		"Location", "Photographer", "Aircraft", "Airline",
+		"Serial", "MSN", "LineNumber", "Hash",
+	}
+	aircraftHeader = []string{"Reg", "TypeCode"}
+)
+
+// WriteTable writes one row per flight or photo of each result, keyed
+// by registration, after a header line. comma is ',' for CSV and '\t'
+// for TSV.
+func WriteTable(w io.Writer, comma rune, table Table, results map[string]*sites.ScrapeResult) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	regs := make([]string, 0, len(results))
+	for reg := range results {
+		regs = append(regs, reg)
+	}
+	sort.Strings(regs)
+
+	switch table {
+	case TableFlights:
+		cw.Write(flightsHeader)
+		for _, reg := range regs {
+			fr := results[reg].FlightRadar
+			if fr == nil {
+				continue
+			}
+			for _, f := range fr.Flights {
+				cw.Write([]string{
+					reg, f.Date, f.Flight, f.From, f.To,
+					f.FlightTime, f.STD, f.ATD, f.STA, f.Status,
+				})
+			}
+		}
+
+	case TablePhotos:
+		cw.Write(photosHeader)
+		for _, reg := range regs {
+			jp := results[reg].JetPhotos
+			if jp == nil {
+				continue
+			}
+			for _, i := range jp.Images {
+				cw.Write([]string{
+					reg, i.ID, i.Link, i.Image, i.Thumbnail, i.DateTaken, i.DateUploaded,
+					i.Location, i.Photographer, i.Aircraft, i.Airline,
+					i.Serial, i.MSN, i.LineNumber, i.Hash,
+				})
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown table %q", table)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteAircraft writes one row per aircraft of a fleet after a header
+// line.
+func WriteAircraft(w io.Writer, comma rune, aircraft []*sites.FleetAircraft) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	cw.Write(aircraftHeader)
+	for _, a := range aircraft {
+		cw.Write([]string{a.Reg, a.TypeCode})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,147 @@
+package images
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DiskCache keeps files in a directory up to a total size, evicting the
+// least recently used once it is exceeded.
+type DiskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*diskEntry
+}
+
+type diskEntry struct {
+	size int64
+	used time.Time
+}
+
+// OpenDiskCache opens the cache in dir, keeping the files left there
+// by a previous run.
+func OpenDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &DiskCache{dir: dir, maxBytes: maxBytes, entries: map[string]*diskEntry{}}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		// left behind by an interrupted Put
+		if filepath.Ext(f.Name()) == ".tmp" {
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		c.entries[f.Name()] = &diskEntry{size: info.Size(), used: info.ModTime()}
+		c.size += info.Size()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	return c, nil
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	name := cacheName(key)
+
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	if ok {
+		e.used = time.Now()
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	b, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, false
+	}
+	// the modification time keeps the order of use across restarts
+	os.Chtimes(filepath.Join(c.dir, name), time.Time{}, time.Now())
+	return b, true
+}
+
+func (c *DiskCache) Put(key string, data []byte) error {
+	name := cacheName(key)
+	if int64(len(data)) > c.maxBytes {
+		return errors.New("too large to cache")
+	}
+
+	f, err := os.CreateTemp(c.dir, name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries[name]; ok {
+		c.size -= old.size
+	}
+	c.entries[name] = &diskEntry{size: int64(len(data)), used: time.Now()}
+	c.size += int64(len(data))
+	c.evict()
+	return nil
+}
+
+// evict removes the least recently used files until the cache fits.
+// c.mu must be held.
+func (c *DiskCache) evict() {
+	for c.size > c.maxBytes {
+		var oldest string
+		for name, e := range c.entries {
+			if oldest == "" || e.used.Before(c.entries[oldest].used) {
+				oldest = name
+			}
+		}
+		if oldest == "" {
+			return
+		}
+
+		os.Remove(filepath.Join(c.dir, oldest))
+		c.size -= c.entries[oldest].size
+		delete(c.entries, oldest)
+	}
+}
+
+// cacheName returns a file name for key that is safe to use whatever
+// key contains.
+func cacheName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,213 @@
+package images
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+	"github.com/macsencasaus/jetapi/internal/sites"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// largest width or height served
+	MaxDimension = 2048
+
+	maxOriginalBytes  = 20 << 20
+	maxOriginalPixels = 50_000_000
+	jpegQuality       = 85
+)
+
+var ErrTooLarge = errors.New("image too large")
+
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+)
+
+// Options picks the variant of an image to serve. The image is scaled
+// to fit Width by Height, zero leaving a side unbounded. The zero
+// Options is the original image.
+type Options struct {
+	Width  int
+	Height int
+	// default jpeg when resizing
+	Format Format
+}
+
+type Image struct {
+	Data        []byte
+	ContentType string
+	// identifies the variant, for ETag headers
+	Tag string
+	// photographer credited by JetPhotos, and the photo page
+	Photographer string
+	Link         string
+}
+
+// photoMeta is cached next to the original image
+type photoMeta struct {
+	ContentType  string `json:"ContentType"`
+	Photographer string `json:"Photographer"`
+	Link         string `json:"Link"`
+}
+
+// Proxy serves JetPhotos images by photo ID. Each original is fetched
+// once into the disk cache, which also keeps the variants made from it.
+type Proxy struct {
+	cache *DiskCache
+	group singleflight.Group
+}
+
+func NewProxy(cache *DiskCache) *Proxy {
+	return &Proxy{cache: cache}
+}
+
+// Get returns the photo with JetPhotos ID id as opts asks for.
+func (p *Proxy) Get(id string, opts Options) (*Image, error) {
+	meta, original, err := p.original(id)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{Photographer: meta.Photographer, Link: meta.Link}
+
+	if opts == (Options{}) {
+		img.Data = original
+		img.ContentType = meta.ContentType
+		img.Tag = cacheName(originalKey(id))[:16]
+		return img, nil
+	}
+
+	if opts.Format == "" {
+		opts.Format = FormatJPEG
+	}
+	key := fmt.Sprintf("variant/%s/%dx%d.%s", id, opts.Width, opts.Height, opts.Format)
+
+	data, ok := p.cache.Get(key)
+	if !ok {
+		v, err, _ := p.group.Do(key, func() (any, error) {
+			data, err := encodeVariant(original, opts)
+			if err != nil {
+				return nil, err
+			}
+			p.cache.Put(key, data)
+			return data, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		data = v.([]byte)
+	}
+
+	img.Data = data
+	img.ContentType = "image/" + string(opts.Format)
+	img.Tag = cacheName(key)[:16]
+	return img, nil
+}
+
+func originalKey(id string) string { return "original/" + id }
+
+func metaKey(id string) string { return "meta/" + id }
+
+// original returns the photo as JetPhotos serves it, from the cache or
+// else from its photo page.
+func (p *Proxy) original(id string) (*photoMeta, []byte, error) {
+	meta := &photoMeta{}
+	b, ok := p.cache.Get(metaKey(id))
+	if ok && json.Unmarshal(b, meta) == nil {
+		if original, ok := p.cache.Get(originalKey(id)); ok {
+			return meta, original, nil
+		}
+	}
+
+	type fetched struct {
+		meta     *photoMeta
+		original []byte
+	}
+	v, err, _ := p.group.Do(originalKey(id), func() (any, error) {
+		photo, err := sites.ScrapePhoto(id)
+		if err != nil {
+			return nil, err
+		}
+
+		body, ctype, err := scraper.FetchImage(photo.Image)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		original, err := io.ReadAll(io.LimitReader(body, maxOriginalBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(original) > maxOriginalBytes {
+			return nil, ErrTooLarge
+		}
+
+		meta := &photoMeta{
+			ContentType:  ctype,
+			Photographer: photo.Photographer,
+			Link:         photo.Link,
+		}
+		metaJSON, err := json.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+		// the image is still served if it can't be cached
+		p.cache.Put(originalKey(id), original)
+		p.cache.Put(metaKey(id), metaJSON)
+
+		return &fetched{meta, original}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := v.(*fetched)
+	return f.meta, f.original, nil
+}
+
+func encodeVariant(original []byte, opts Options) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxOriginalPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+
+	var dst image.Image = src
+	b := src.Bounds()
+	w, h := fitSize(b.Dx(), b.Dy(), opts.Width, opts.Height)
+	if w != b.Dx() || h != b.Dy() {
+		dst = resize(src, w, h)
+	}
+
+	buf := &bytes.Buffer{}
+	switch opts.Format {
+	case FormatJPEG:
+		err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: jpegQuality})
+	case FormatPNG:
+		err = png.Encode(buf, dst)
+	default:
+		err = fmt.Errorf("unknown format %q", opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package images
+
+import (
+	"image"
+	"image/draw"
+)
+
+// fitSize returns the size of a w by h image scaled to fit within
+// maxW by maxH, keeping its aspect ratio. A zero bound is ignored.
+// Images are never scaled up.
+func fitSize(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && maxW < w {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && maxH < h {
+		scale = min(scale, float64(maxH)/float64(h))
+	}
+	return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
+
+// resize scales src down to w by h, averaging the source pixels that
+// fall on each destination pixel.
+func resize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	sw, sh := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy0 := y * sh / h
+		sy1 := max(sy0+1, (y+1)*sh/h)
+
+		for x := 0; x < w; x++ {
+			sx0 := x * sw / w
+			sx1 := max(sx0+1, (x+1)*sw/w)
+
+			var r, g, bl, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				i := rgba.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += int(rgba.Pix[i])
+					g += int(rgba.Pix[i+1])
+					bl += int(rgba.Pix[i+2])
+					a += int(rgba.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,77 @@
+package phash
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+)
+
+// Hash is a 64 bit perceptual hash, which stays close for images that
+// look alike after scaling, recompression or small edits.
+type Hash uint64
+
+// DHash returns the difference hash of img. The image is reduced to a
+// 9x8 grid of luminance, and each bit records whether a cell is
+// brighter than its right neighbour.
+func DHash(img image.Image) Hash {
+	grid := grayGrid(img, 9, 8)
+
+	var h Hash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// Distance is the number of bits a and b differ in, 0 for images that
+// look the same.
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+func Parse(s string) (Hash, error) {
+	n, err := strconv.ParseUint(s, 16, 64)
+	return Hash(n), err
+}
+
+// grayGrid returns the mean luminance of each cell of img divided into
+// w by h cells.
+func grayGrid(img image.Image, w, h int) [][]float64 {
+	b := img.Bounds()
+	sums := make([][]float64, h)
+	counts := make([][]int, h)
+	for y := range sums {
+		sums[y] = make([]float64, w)
+		counts[y] = make([]int, w)
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * h / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * w / b.Dx()
+			r, g, bl, _ := img.At(x, y).RGBA()
+			// ITU-R BT.601 luma
+			sums[cy][cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			counts[cy][cx]++
+		}
+	}
+
+	for y := range sums {
+		for x := range sums[y] {
+			if counts[y][x] > 0 {
+				sums[y][x] /= float64(counts[y][x])
+			}
+		}
+	}
+	return sums
+}
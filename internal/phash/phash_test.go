@@ -0,0 +1,91 @@
+package phash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gradient returns a w by h image whose gray level at x is shade(x, w).
+func gradient(w, h int, shade func(x, w int) uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: shade(x, w)})
+		}
+	}
+	return img
+}
+
+func darkening(x, w int) uint8   { return uint8(255 - 255*x/w) }
+func brightening(x, w int) uint8 { return uint8(255 * x / w) }
+
+func TestDHash(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		want Hash
+	}{
+		{"solid", gradient(90, 80, func(int, int) uint8 { return 128 }), 0},
+		{"brightening", gradient(90, 80, brightening), 0},
+		{"darkening", gradient(90, 80, darkening), 0xffffffffffffffff},
+		{"darkening scaled", gradient(900, 40, darkening), 0xffffffffffffffff},
+		{"offset bounds", gradient(90, 80, darkening).(*image.Gray).SubImage(image.Rect(9, 0, 90, 80)), 0xffffffffffffffff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DHash(tt.img); got != tt.want {
+				t.Errorf("DHash = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b Hash
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff, 0x0f, 4},
+		{0, 0xffffffffffffffff, 64},
+		{0x8000000000000001, 0x0000000000000001, 1},
+	}
+
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Hash
+		err  bool
+	}{
+		{"0000000000000000", 0, false},
+		{"00000000000000ff", 0xff, false},
+		{"ffffffffffffffff", 0xffffffffffffffff, false},
+		{"", 0, true},
+		{"not a hash", 0, true},
+		{"1ffffffffffffffff", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("Parse(%q) returned error %v", tt.s, err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+		if err == nil && got.String() != tt.s {
+			t.Errorf("Parse(%q).String() = %s", tt.s, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// buckets idle for this long are full again and can be dropped
+const idleAfter = 10 * time.Minute
+
+// Limiter is a token bucket per client, refilling at a steady rate up
+// to burst tokens.
+type Limiter struct {
+	perSecond float64
+	burst     float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter allowing perMinute tokens a minute to each
+// client, and up to burst at once.
+func New(perMinute, burst int) *Limiter {
+	return &Limiter{
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(burst),
+		buckets:   map[string]*bucket{},
+	}
+}
+
+// AllowN takes n tokens from the bucket of key. If there aren't
+// enough, none are taken and it returns how long until there will be.
+func (l *Limiter) AllowN(key string, n int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+
+	need := float64(n)
+	if need > l.burst {
+		// could never be allowed, so don't suggest a retry
+		return false, 0
+	}
+	if b.tokens < need {
+		wait := (need - b.tokens) / l.perSecond
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	b.tokens -= need
+	return true, 0
+}
+
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	return l.AllowN(key, 1, now)
+}
+
+// sweep drops the buckets of clients that have gone quiet. l.mu must
+// be held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleAfter {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleAfter {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowN(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	type take struct {
+		after time.Duration
+		n     int
+		ok    bool
+		wait  time.Duration
+	}
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "burst then refill",
+			takes: []take{
+				{0, 60, true, 0},
+				{0, 1, false, time.Second},
+				{time.Second, 1, true, 0},
+			},
+		},
+		{
+			name: "more than the burst",
+			takes: []take{
+				{0, 61, false, 0},
+				{0, 60, true, 0},
+			},
+		},
+		{
+			name: "short tokens are not taken",
+			takes: []take{
+				{0, 50, true, 0},
+				{0, 20, false, 10 * time.Second},
+				{0, 10, true, 0},
+			},
+		},
+		{
+			name: "refill stops at the burst",
+			takes: []take{
+				{0, 60, true, 0},
+				{5 * time.Minute, 60, true, 0},
+				{0, 1, false, time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(60, 60)
+			now := start
+			for i, tk := range tt.takes {
+				now = now.Add(tk.after)
+				ok, wait := l.AllowN("client", tk.n, now)
+				if ok != tk.ok || wait != tk.wait {
+					t.Errorf("take %d of %d = %v, %v, want %v, %v", i, tk.n, ok, wait, tk.ok, tk.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowSeparatesKeys(t *testing.T) {
+	l := New(60, 1)
+	now := time.Now()
+
+	if ok, _ := l.Allow("a", now); !ok {
+		t.Fatal("first request of a denied")
+	}
+	if ok, _ := l.Allow("a", now); ok {
+		t.Fatal("second request of a allowed")
+	}
+	if ok, _ := l.Allow("b", now); !ok {
+		t.Fatal("first request of b denied")
+	}
+}
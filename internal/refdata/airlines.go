@@ -0,0 +1,101 @@
+package refdata
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type Airline struct {
+	ICAO     string `json:"ICAO"`
+	IATA     string `json:"IATA"`
+	Name     string `json:"Name"`
+	Callsign string `json:"Callsign"`
+	Country  string `json:"Country"`
+	Alliance string `json:"Alliance"`
+}
+
+type airlineIndex struct {
+	byICAO map[string]*Airline
+	byIATA map[string]*Airline
+}
+
+//go:embed airlines.csv
+var airlinesCSV []byte
+
+var airlines *airlineIndex
+
+func init() {
+	var err error
+	airlines, err = loadAirlines(bytes.NewReader(airlinesCSV))
+	if err != nil {
+		panic(fmt.Sprintf("loading airlines: %v", err))
+	}
+}
+
+// LoadAirlinesFile replaces the embedded airline data with the CSV
+// file at path, which uses the same columns as airlines.csv.
+// It is not safe to call while lookups are running.
+func LoadAirlinesFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	index, err := loadAirlines(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	airlines = index
+	return nil
+}
+
+// LookupAirline finds an airline by ICAO or IATA code. FR24 codes
+// such as "BA/BAW" are split and the ICAO code is preferred.
+func LookupAirline(code string) (*Airline, bool) {
+	codes := strings.Split(strings.ToUpper(code), "/")
+	for _, c := range codes {
+		if a, ok := airlines.byICAO[strings.TrimSpace(c)]; ok {
+			return a, true
+		}
+	}
+	for _, c := range codes {
+		if a, ok := airlines.byIATA[strings.TrimSpace(c)]; ok {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+func loadAirlines(r io.Reader) (*airlineIndex, error) {
+	records, err := readCSV(r, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &airlineIndex{
+		byICAO: map[string]*Airline{},
+		byIATA: map[string]*Airline{},
+	}
+	for _, rec := range records {
+		a := &Airline{
+			ICAO:     strings.ToUpper(rec[0]),
+			IATA:     strings.ToUpper(rec[1]),
+			Name:     rec[2],
+			Callsign: rec[3],
+			Country:  rec[4],
+			Alliance: rec[5],
+		}
+		if a.ICAO != "" {
+			index.byICAO[a.ICAO] = a
+		}
+		if a.IATA != "" {
+			index.byIATA[a.IATA] = a
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,130 @@
+package refdata
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	// the embedded airports must load on hosts without a zoneinfo
+	// database
+	_ "time/tzdata"
+)
+
+type Airport struct {
+	IATA      string  `json:"IATA"`
+	ICAO      string  `json:"ICAO"`
+	Name      string  `json:"Name"`
+	City      string  `json:"City"`
+	Country   string  `json:"Country"`
+	Latitude  float64 `json:"Latitude"`
+	Longitude float64 `json:"Longitude"`
+	TimeZone  string  `json:"TimeZone"`
+
+	location *time.Location
+}
+
+type airportIndex struct {
+	byIATA map[string]*Airport
+	byICAO map[string]*Airport
+}
+
+//go:embed airports.csv
+var airportsCSV []byte
+
+var airports *airportIndex
+
+func init() {
+	var err error
+	airports, err = loadAirports(bytes.NewReader(airportsCSV))
+	if err != nil {
+		panic(fmt.Sprintf("loading airports: %v", err))
+	}
+}
+
+// LoadAirportsFile replaces the embedded airport data with the CSV
+// file at path, which uses the same columns as airports.csv.
+// It is not safe to call while lookups are running.
+func LoadAirportsFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	index, err := loadAirports(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	airports = index
+	return nil
+}
+
+// LookupAirport finds an airport by IATA or ICAO code. FR24 names such
+// as "London (LHR)" are looked up by the code in parentheses.
+func LookupAirport(code string) (*Airport, bool) {
+	code = strings.TrimSpace(code)
+	if i := strings.LastIndex(code, "("); i != -1 {
+		code = strings.TrimSuffix(code[i+1:], ")")
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	if a, ok := airports.byIATA[code]; ok {
+		return a, true
+	}
+	a, ok := airports.byICAO[code]
+	return a, ok
+}
+
+// Location returns the airport's timezone.
+func (a *Airport) Location() *time.Location {
+	return a.location
+}
+
+func loadAirports(r io.Reader) (*airportIndex, error) {
+	records, err := readCSV(r, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &airportIndex{
+		byIATA: map[string]*Airport{},
+		byICAO: map[string]*Airport{},
+	}
+	for _, rec := range records {
+		lat, err := strconv.ParseFloat(rec[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("latitude of %s: %v", rec[0], err)
+		}
+		lon, err := strconv.ParseFloat(rec[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("longitude of %s: %v", rec[0], err)
+		}
+		loc, err := time.LoadLocation(rec[7])
+		if err != nil {
+			return nil, fmt.Errorf("timezone of %s: %v", rec[0], err)
+		}
+
+		a := &Airport{
+			IATA:      strings.ToUpper(rec[0]),
+			ICAO:      strings.ToUpper(rec[1]),
+			Name:      rec[2],
+			City:      rec[3],
+			Country:   rec[4],
+			Latitude:  lat,
+			Longitude: lon,
+			TimeZone:  rec[7],
+			location:  loc,
+		}
+		if a.IATA != "" {
+			index.byIATA[a.IATA] = a
+		}
+		if a.ICAO != "" {
+			index.byICAO[a.ICAO] = a
+		}
+	}
+	return index, nil
+}
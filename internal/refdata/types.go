@@ -0,0 +1,89 @@
+package refdata
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type AircraftType struct {
+	Designator   string `json:"Designator"`
+	Manufacturer string `json:"Manufacturer"`
+	Model        string `json:"Model"`
+	Family       string `json:"Family"`
+	Engines      int    `json:"Engines"`
+	EngineType   string `json:"EngineType"`
+	WakeCategory string `json:"WakeCategory"`
+	TypicalSeats int    `json:"TypicalSeats"`
+}
+
+//go:embed aircraft_types.csv
+var aircraftTypesCSV []byte
+
+var aircraftTypes map[string]*AircraftType
+
+func init() {
+	var err error
+	aircraftTypes, err = loadTypes(bytes.NewReader(aircraftTypesCSV))
+	if err != nil {
+		panic(fmt.Sprintf("loading aircraft types: %v", err))
+	}
+}
+
+// LookupType finds an aircraft type by its ICAO designator, e.g. "B77W".
+func LookupType(designator string) (*AircraftType, bool) {
+	t, ok := aircraftTypes[strings.ToUpper(strings.TrimSpace(designator))]
+	return t, ok
+}
+
+func loadTypes(r io.Reader) (map[string]*AircraftType, error) {
+	records, err := readCSV(r, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[string]*AircraftType{}
+	for _, rec := range records {
+		engines, err := strconv.Atoi(rec[4])
+		if err != nil {
+			return nil, fmt.Errorf("engines of %s: %v", rec[0], err)
+		}
+		seats, err := strconv.Atoi(rec[7])
+		if err != nil {
+			return nil, fmt.Errorf("seats of %s: %v", rec[0], err)
+		}
+
+		t := &AircraftType{
+			Designator:   strings.ToUpper(rec[0]),
+			Manufacturer: rec[1],
+			Model:        rec[2],
+			Family:       rec[3],
+			Engines:      engines,
+			EngineType:   rec[5],
+			WakeCategory: rec[6],
+			TypicalSeats: seats,
+		}
+		types[t.Designator] = t
+	}
+	return types, nil
+}
+
+// readCSV reads every record after the header, each with n fields.
+func readCSV(r io.Reader, n int) ([][]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = n
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header")
+	}
+	return records[1:], nil
+}
@@ -66,21 +66,175 @@ func (s *Scraper) Advance(startTag, class string, count int) error {
 	return err
 }
 
-func (s *Scraper) TryScrapeText() (string, bool) {
-	tt := s.tokenizer.Next()
-	t := s.tokenizer.Token()
+// AdvanceUntil moves past the next startTag with class, unless a
+// stopTag with stopClass comes first. Then it stops just before the
+// stopTag and returns false.
+func (s *Scraper) AdvanceUntil(startTag, class, stopTag, stopClass string) (bool, error) {
+	if s.tokenizer == nil {
+		s.tokenizer = html.NewTokenizer(s.body)
+	}
 
-	for strings.TrimSpace(t.Data) == "" {
-		tt = s.tokenizer.Next()
-		t = s.tokenizer.Token()
+	for {
+		t, err := s.popToken()
+		if err != nil {
+			return false, err
+		}
+		if t.Type != html.StartTagToken {
+			continue
+		}
+		if t.Data == stopTag && tokenHasClass(&t, stopClass) {
+			s.tokens = append([]html.Token{t}, s.tokens...)
+			return false, nil
+		}
+		if t.Data == startTag && tokenHasClass(&t, class) {
+			return true, nil
+		}
 	}
+}
 
-	if tt != html.TextToken {
+func (s *Scraper) ScrapeLinkPrefix(startTag, prefix string) (string, error) {
+	return s.ScrapeLinkFunc(startTag, func(link string) bool {
+		return strings.HasPrefix(link, prefix)
+	})
+}
+
+// ScrapeLinkFunc returns the href of the next startTag for which match
+// returns true.
+func (s *Scraper) ScrapeLinkFunc(startTag string, match func(string) bool) (string, error) {
+	if s.tokenizer == nil {
+		s.tokenizer = html.NewTokenizer(s.body)
+	}
+
+	for i, t := range s.tokens {
+		if link, ok := matchLink(&t, startTag, match); ok {
+			s.tokens = s.tokens[i+1:]
+			return link, nil
+		}
+	}
+
+	for {
+		tokenType := s.tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if s.tokenizer.Err() == io.EOF {
+				return "", s.Errorf("tag '%s' with matching link not found", startTag)
+			}
+			return "", s.Errorf("Error tokenizing html: %v", s.tokenizer.Err())
+		}
+		t := s.tokenizer.Token()
 		s.tokens = append(s.tokens, t)
-		return "", false
+
+		if link, ok := matchLink(&t, startTag, match); ok {
+			s.tokens = s.tokens[len(s.tokens):]
+			return link, nil
+		}
+	}
+}
+
+// ScrapeLinkUntil is ScrapeLinkFunc stopping at the next stopTag with
+// stopClass. If that comes first it is left to be read and ok is false.
+func (s *Scraper) ScrapeLinkUntil(
+	startTag string,
+	match func(string) bool,
+	stopTag, stopClass string,
+) (link string, ok bool, err error) {
+	if s.tokenizer == nil {
+		s.tokenizer = html.NewTokenizer(s.body)
 	}
 
-	return t.Data, true
+	for {
+		t, err := s.popToken()
+		if err != nil {
+			return "", false, err
+		}
+		if t.Type == html.StartTagToken && t.Data == stopTag && tokenHasClass(&t, stopClass) {
+			s.tokens = append([]html.Token{t}, s.tokens...)
+			return "", false, nil
+		}
+		if link, ok := matchLink(&t, startTag, match); ok {
+			return link, true, nil
+		}
+	}
+}
+
+// ScrapeRow reads the next table row with the given class, returning
+// the text of each cell and every link inside the row.
+func (s *Scraper) ScrapeRow(class string) ([]string, []string, error) {
+	if s.tokenizer == nil {
+		s.tokenizer = html.NewTokenizer(s.body)
+	}
+	_, err := s.nextToken(html.StartTagToken, "tr", class)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cells := []string{}
+	links := []string{}
+	var cell strings.Builder
+	inCell := false
+
+	for {
+		t, err := s.popToken()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case t.Type == html.StartTagToken && (t.Data == "td" || t.Data == "th"):
+			cell.Reset()
+			inCell = true
+		case t.Type == html.EndTagToken && (t.Data == "td" || t.Data == "th"):
+			cells = append(cells, strings.Join(strings.Fields(cell.String()), " "))
+			inCell = false
+		case t.Type == html.TextToken && inCell:
+			cell.WriteString(t.Data)
+			cell.WriteString(" ")
+		case t.Type == html.StartTagToken && t.Data == "a":
+			for _, attr := range t.Attr {
+				if attr.Key == "href" {
+					links = append(links, attr.Val)
+				}
+			}
+		case t.Type == html.EndTagToken && t.Data == "tr":
+			return cells, links, nil
+		}
+	}
+}
+
+// popToken returns the next token, reading buffered tokens first.
+func (s *Scraper) popToken() (html.Token, error) {
+	if len(s.tokens) > 0 {
+		t := s.tokens[0]
+		s.tokens = s.tokens[1:]
+		return t, nil
+	}
+
+	if s.tokenizer.Next() == html.ErrorToken {
+		return html.Token{}, s.Errorf("Error tokenizing html: %v", s.tokenizer.Err())
+	}
+	return s.tokenizer.Token(), nil
+}
+
+// TryScrapeText returns the next text that isn't blank. If a tag or
+// the end of the page comes first it returns false, leaving the tag to
+// be read.
+func (s *Scraper) TryScrapeText() (string, bool) {
+	if s.tokenizer == nil {
+		s.tokenizer = html.NewTokenizer(s.body)
+	}
+
+	for {
+		t, err := s.popToken()
+		if err != nil {
+			return "", false
+		}
+		if t.Type != html.TextToken {
+			s.tokens = append([]html.Token{t}, s.tokens...)
+			return "", false
+		}
+		if strings.TrimSpace(t.Data) != "" {
+			return t.Data, true
+		}
+	}
 }
 
 func (s *Scraper) scrapeNextTokens(
@@ -106,8 +260,13 @@ func (s *Scraper) scrapeNextTokens(
 		}
 
 		if tt == html.TextToken {
-			s.tokenizer.Next()
-			token = s.tokenizer.Token()
+			token, err = s.popToken()
+			if err != nil {
+				if atLeastOne {
+					break
+				}
+				return nil, err
+			}
 		}
 
 		if action == SCRAPE {
@@ -167,7 +326,51 @@ func tokenHasClass(t *html.Token, class string) bool {
 	return false
 }
 
+func matchLink(t *html.Token, startTag string, match func(string) bool) (string, bool) {
+	if t.Type != html.StartTagToken || t.Data != startTag {
+		return "", false
+	}
+
+	for _, attr := range t.Attr {
+		if attr.Key == "href" && match(attr.Val) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
 func FetchHTML(URL string) (io.ReadCloser, error) {
+	resp, err := fetch(URL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctype := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ctype, "text/html") {
+		resp.Body.Close()
+		return nil, fmt.Errorf("content not type text/html")
+	}
+
+	return resp.Body, nil
+}
+
+// FetchImage returns the body of the image at URL and its content type.
+func FetchImage(URL string) (io.ReadCloser, string, error) {
+	resp, err := fetch(URL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctype := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ctype, "image/") {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("content not type image")
+	}
+
+	return resp.Body, ctype, nil
+}
+
+func fetch(URL string) (*http.Response, error) {
 	tlsConfig := &tls.Config{
 		CipherSuites: []uint16{
 			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
@@ -214,10 +417,5 @@ func FetchHTML(URL string) (io.ReadCloser, error) {
 		}
 	}
 
-	ctype := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(ctype, "text/html") {
-		return nil, fmt.Errorf("content not type text/html")
-	}
-
-	return resp.Body, nil
+	return resp, nil
 }
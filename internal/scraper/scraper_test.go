@@ -0,0 +1,211 @@
+package scraper
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestScraper(page string) *Scraper {
+	return NewScraper(io.NopCloser(strings.NewReader(page)))
+}
+
+func TestAdvanceUntil(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  string
+		found bool
+		err   bool
+		// the link read after AdvanceUntil returns
+		next string
+	}{
+		{
+			name:  "found",
+			page:  `<span class="a">1</span><a class="stop" href="/next">`,
+			found: true,
+			next:  "/next",
+		},
+		{
+			name:  "stopped",
+			page:  `<a class="stop" href="/next"><span class="a">1</span>`,
+			found: false,
+			next:  "/next",
+		},
+		{
+			name: "end of page",
+			page: `<span class="b">1</span>`,
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(tt.page)
+			found, err := s.AdvanceUntil("span", "a", "a", "stop")
+			if (err != nil) != tt.err {
+				t.Fatalf("AdvanceUntil returned error %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if found != tt.found {
+				t.Errorf("found = %v, want %v", found, tt.found)
+			}
+			links, err := s.ScrapeLinks("a", "stop", 1)
+			if err != nil || links[0] != tt.next {
+				t.Errorf("next link = %v, %v, want %s", links, err, tt.next)
+			}
+		})
+	}
+}
+
+func TestScrapeLinkUntil(t *testing.T) {
+	isReg := func(link string) bool { return strings.HasPrefix(link, "/data/aircraft/") }
+
+	tests := []struct {
+		name string
+		page string
+		link string
+		ok   bool
+		err  bool
+	}{
+		{
+			name: "in the row",
+			page: `<tr class="row"><td><a href="/data/flights/ba1">BA1</a><a href="/data/aircraft/g-xlea">G-XLEA</a></td></tr>`,
+			link: "/data/aircraft/g-xlea",
+			ok:   true,
+		},
+		{
+			name: "in the next row",
+			page: `<tr class="row"><td>BA1</td></tr><tr class="row"><td><a href="/data/aircraft/g-xleb">G-XLEB</a></td></tr>`,
+			ok:   false,
+		},
+		{
+			name: "end of page",
+			page: `<tr class="row"><td>BA1</td></tr>`,
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(tt.page)
+			if err := s.Advance("tr", "row", 1); err != nil {
+				t.Fatal(err)
+			}
+			link, ok, err := s.ScrapeLinkUntil("a", isReg, "tr", "row")
+			if (err != nil) != tt.err {
+				t.Fatalf("ScrapeLinkUntil returned error %v", err)
+			}
+			if link != tt.link || ok != tt.ok {
+				t.Errorf("ScrapeLinkUntil = %q, %v, want %q, %v", link, ok, tt.link, tt.ok)
+			}
+			if ok || err != nil {
+				return
+			}
+			// the next row is left to be read
+			if err := s.Advance("tr", "row", 1); err != nil {
+				t.Errorf("next row lost: %v", err)
+			}
+		})
+	}
+}
+
+func TestScrapeRow(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  string
+		class string
+		cells []string
+		links []string
+		err   bool
+	}{
+		{
+			name:  "cells and links",
+			page:  `<table><tr class="row"><td> 01 May  2024 </td><td><a href="/data/flights/ba1">BA1</a></td><td></td></tr></table>`,
+			class: "row",
+			cells: []string{"01 May 2024", "BA1", ""},
+			links: []string{"/data/flights/ba1"},
+		},
+		{
+			name:  "skips other rows",
+			page:  `<tr class="head"><th>Date</th></tr><tr class="row"><td>BA1</td></tr>`,
+			class: "row",
+			cells: []string{"BA1"},
+			links: []string{},
+		},
+		{
+			name:  "no row",
+			page:  `<table></table>`,
+			class: "row",
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cells, links, err := newTestScraper(tt.page).ScrapeRow(tt.class)
+			if (err != nil) != tt.err {
+				t.Fatalf("ScrapeRow returned error %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(cells, tt.cells) || !reflect.DeepEqual(links, tt.links) {
+				t.Errorf("ScrapeRow = %q, %q, want %q, %q", cells, links, tt.cells, tt.links)
+			}
+		})
+	}
+}
+
+func TestTryScrapeText(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		text string
+		ok   bool
+		// the text read with ScrapeText when there was none
+		link string
+	}{
+		{"text", `<span class="x">  Private owner </span>`, "  Private owner ", true, ""},
+		{"link", `<span class="x"> <a href="/a">British Airways</a></span>`, "", false, "British Airways"},
+		{"end of page", `<span class="x">   `, "", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(tt.page)
+			if err := s.Advance("span", "x", 1); err != nil {
+				t.Fatal(err)
+			}
+			text, ok := s.TryScrapeText()
+			if text != tt.text || ok != tt.ok {
+				t.Errorf("TryScrapeText = %q, %v, want %q, %v", text, ok, tt.text, tt.ok)
+			}
+			if tt.link == "" {
+				return
+			}
+			res, err := s.ScrapeText("a", "", 1)
+			if err != nil || res[0] != tt.link {
+				t.Errorf("text after TryScrapeText = %q, %v, want %q", res, err, tt.link)
+			}
+		})
+	}
+}
+
+func TestTryScrapeTextAfterAdvanceUntil(t *testing.T) {
+	s := newTestScraper(`<span class="a"></span><a class="stop" href="/next">Next</a>`)
+	if found, err := s.AdvanceUntil("span", "b", "a", "stop"); found || err != nil {
+		t.Fatalf("AdvanceUntil = %v, %v", found, err)
+	}
+
+	// the stop tag pushed back by AdvanceUntil comes first
+	if text, ok := s.TryScrapeText(); ok {
+		t.Fatalf("TryScrapeText read %q past the stop tag", text)
+	}
+	res, err := s.ScrapeText("a", "stop", 1)
+	if err != nil || res[0] != "Next" {
+		t.Errorf("ScrapeText = %q, %v, want Next", res, err)
+	}
+}
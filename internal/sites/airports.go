@@ -0,0 +1,110 @@
+package sites
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+)
+
+type AirportResult struct {
+	Airport    string        `json:"Airport"`
+	Arrivals   []*BoardEntry `json:"Arrivals"`
+	Departures []*BoardEntry `json:"Departures"`
+}
+
+type BoardEntry struct {
+	Flight  string `json:"Flight"`
+	Airline string `json:"Airline"`
+	// origin for arrivals, destination for departures
+	Airport   string `json:"Airport"`
+	Reg       string `json:"Reg"`
+	TypeCode  string `json:"TypeCode"`
+	Scheduled string `json:"Scheduled"`
+	Estimated string `json:"Estimated"`
+	Status    string `json:"Status"`
+	// link to the aircraft page of this site
+	AircraftLink string `json:"AircraftLink"`
+}
+
+const frAirportURL = "https://www.flightradar24.com/data/airports/"
+
+// FR24 statuses read like "Estimated dep 10:20" or "Landed 09:55"
+var boardTime = regexp.MustCompile(`\d{1,2}:\d{2}(\s?[AP]M)?`)
+
+func ScrapeAirport(code string, limit int) (*AirportResult, error) {
+	code = strings.ToLower(code)
+	response := &AirportResult{Airport: strings.ToUpper(code)}
+
+	var err error
+	response.Arrivals, err = scrapeBoard(code, "arrivals", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Departures, err = scrapeBoard(code, "departures", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func scrapeBoard(code, board string, limit int) ([]*BoardEntry, error) {
+	URL := fmt.Sprintf("%s%s/%s", frAirportURL, code, board)
+	b, err := scraper.FetchHTML(URL)
+	if err != nil {
+		return nil, frError("fetching fr airport page", code, URL, err)
+	}
+
+	s := scraper.NewScraper(b)
+	defer s.Close()
+
+	entries := []*BoardEntry{}
+	for len(entries) < limit {
+		cells, links, err := s.ScrapeRow("")
+		if err != nil {
+			break
+		}
+		// columns: time, flight, airport, airline, aircraft, status
+		if len(cells) < 6 {
+			continue
+		}
+
+		entry := &BoardEntry{
+			Scheduled: cells[0],
+			Flight:    cells[1],
+			Airport:   cells[2],
+			Airline:   cells[3],
+			Status:    cells[5],
+			Estimated: boardTime.FindString(cells[5]),
+		}
+
+		isFlight := false
+		for _, link := range links {
+			if strings.HasPrefix(link, "/data/flights/") {
+				isFlight = true
+			}
+			if strings.HasPrefix(link, "/data/aircraft/") {
+				entry.Reg = strings.ToUpper(path.Base(link))
+			}
+		}
+		// header and date separator rows
+		if !isFlight {
+			continue
+		}
+
+		// aircraft cell reads "A388 (G-XLEA)"
+		entry.TypeCode, _, _ = strings.Cut(cells[4], " ")
+		if entry.Reg != "" {
+			entry.AircraftLink = "/aircraft?reg=" + url.QueryEscape(entry.Reg)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,80 @@
+package sites
+
+import (
+	"context"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/macsencasaus/jetapi/internal/phash"
+	"github.com/macsencasaus/jetapi/internal/scraper"
+	"golang.org/x/sync/errgroup"
+)
+
+// largest hash distance between photos taken to be the same shot, out
+// of 64 bits
+const dedupeDistance = 10
+
+// hashThumbnails sets the Hash of each image from its thumbnail. An
+// image whose thumbnail can't be read is left without one, and is
+// never taken as a duplicate.
+func hashThumbnails(images []ImageAttributes) {
+	g, _ := errgroup.WithContext(context.Background())
+	g.SetLimit(jpPageWorkers)
+
+	for i := range images {
+		g.Go(func() error {
+			h, err := thumbnailHash(images[i].Thumbnail)
+			if err == nil {
+				images[i].Hash = h.String()
+			}
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+func thumbnailHash(URL string) (phash.Hash, error) {
+	body, _, err := scraper.FetchImage(URL)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	img, _, err := image.Decode(body)
+	if err != nil {
+		return 0, err
+	}
+	return phash.DHash(img), nil
+}
+
+// dedupeImages adds cards to kept, moving each card that looks like a
+// photo already kept into that photo's Duplicates.
+func dedupeImages(kept, cards []ImageAttributes) []ImageAttributes {
+	for _, card := range cards {
+		i := findDuplicate(kept, card)
+		if i == -1 {
+			kept = append(kept, card)
+			continue
+		}
+		kept[i].Duplicates = append(kept[i].Duplicates, card)
+	}
+	return kept
+}
+
+func findDuplicate(kept []ImageAttributes, card ImageAttributes) int {
+	h, err := phash.Parse(card.Hash)
+	if err != nil {
+		return -1
+	}
+	for i, k := range kept {
+		kh, err := phash.Parse(k.Hash)
+		if err != nil {
+			continue
+		}
+		if phash.Distance(h, kh) <= dedupeDistance {
+			return i
+		}
+	}
+	return -1
+}
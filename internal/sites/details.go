@@ -0,0 +1,165 @@
+package sites
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+)
+
+// AircraftDetails is derived from the serial numbers and dates across
+// the scraped photos and flights.
+type AircraftDetails struct {
+	MSN        string `json:"MSN"`
+	LineNumber string `json:"LineNumber"`
+	// earliest and latest dates among the aircraft's JetPhotos search
+	// results and the flights returned
+	FirstSeen string `json:"FirstSeen"`
+	LastSeen  string `json:"LastSeen"`
+	// estimated from the oldest photo, as neither site shows the
+	// delivery date, and null without one
+	EstimatedDeliveryYear *int     `json:"EstimatedDeliveryYear"`
+	EstimatedAge          *float64 `json:"EstimatedAge"`
+}
+
+const detailsDateLayout = "2006-01-02"
+
+// "38694/1221", "38694 / 1221", "38694 - LN:1221" or just "5678"
+var serialPattern = regexp.MustCompile(`^\s*([0-9A-Za-z-]+?)\s*(?:/|-\s*LN:?|LN:?)\s*([0-9A-Za-z]+)\s*$`)
+
+// ParseSerial splits a JetPhotos serial into the manufacturer serial
+// number and, for types that have one, the line number.
+func ParseSerial(serial string) (string, string) {
+	serial = strings.TrimSpace(serial)
+	if m := serialPattern.FindStringSubmatch(serial); m != nil {
+		return m[1], m[2]
+	}
+	return serial, ""
+}
+
+// BuildDetails derives the details of sr. searchDates are the dates
+// taken of the aircraft's newest and oldest photos on JetPhotos.
+func BuildDetails(sr *ScrapeResult, searchDates []time.Time, now time.Time) *AircraftDetails {
+	details := &AircraftDetails{}
+	var first, last time.Time
+
+	seenAt := func(t time.Time) {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if last.IsZero() || t.After(last) {
+			last = t
+		}
+	}
+	seen := func(date string) {
+		if t, ok := ParseJPDate(date); ok {
+			seenAt(t)
+		}
+	}
+
+	for _, t := range searchDates {
+		seenAt(t)
+	}
+
+	if jp := sr.JetPhotos; jp != nil {
+		for _, image := range jp.Images {
+			if details.MSN == "" && image.MSN != "" {
+				details.MSN = image.MSN
+				details.LineNumber = image.LineNumber
+			}
+			seen(image.DateTaken)
+		}
+	}
+
+	if fr := sr.FlightRadar; fr != nil {
+		for _, flight := range fr.Flights {
+			seen(flight.Date)
+		}
+	}
+
+	if first.IsZero() {
+		return details
+	}
+
+	details.FirstSeen = first.Format(detailsDateLayout)
+	details.LastSeen = last.Format(detailsDateLayout)
+
+	// flights and the photos returned only go back a few months, too
+	// recent to estimate from
+	if len(searchDates) > 0 {
+		year := first.Year()
+		age := math.Round(now.Sub(first).Hours()/24/365.25*10) / 10
+		details.EstimatedDeliveryYear = &year
+		details.EstimatedAge = &age
+	}
+	return details
+}
+
+// scrapeSearchDates returns the dates taken of the photos on the first
+// and last pages of reg's search results sorted by date taken, which
+// hold its newest and oldest photos.
+func scrapeSearchDates(reg string) ([]time.Time, error) {
+	q := &APIQueries{Reg: reg, Filters: PhotoFilters{Sort: SortTaken}}
+
+	URL := jpSearchURL(q, 1)
+	dates, lastPage, err := scrapeSearchDatesPage(reg, URL)
+	if err != nil || lastPage <= 1 {
+		return dates, err
+	}
+
+	URL = jpSearchURL(q, lastPage)
+	oldest, _, err := scrapeSearchDatesPage(reg, URL)
+	return append(dates, oldest...), err
+}
+
+func scrapeSearchDatesPage(reg, URL string) ([]time.Time, int, error) {
+	b, err := scraper.FetchHTML(URL)
+	if err != nil {
+		return nil, 0, jpError("scraping search URL", reg, URL, err)
+	}
+
+	s := scraper.NewScraper(b)
+	defer s.Close()
+
+	dates, lastPage := readSearchDates(s)
+	return dates, lastPage, nil
+}
+
+// readSearchDates returns the dates taken on the result cards in s, and
+// the highest page number its pagination links to.
+func readSearchDates(s *scraper.Scraper) ([]time.Time, int) {
+	dates := []time.Time{}
+	for {
+		_, err := s.ScrapeLinks("a", "result__photoLink", 1)
+		if err != nil {
+			break
+		}
+		var image ImageAttributes
+		scrapeResultCaption(s, &image)
+		if t, ok := ParseJPDate(image.DateTaken); ok {
+			dates = append(dates, t)
+		}
+	}
+
+	lastPage := 1
+	for {
+		link, err := s.ScrapeLinkFunc("a", func(link string) bool {
+			return strings.Contains(link, "page=")
+		})
+		if err != nil {
+			break
+		}
+		u, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		if page, err := strconv.Atoi(u.Query().Get("page")); err == nil {
+			lastPage = max(lastPage, page)
+		}
+	}
+	return dates, lastPage
+}
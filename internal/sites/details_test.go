@@ -0,0 +1,125 @@
+package sites
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSerial(t *testing.T) {
+	tests := []struct {
+		serial string
+		msn    string
+		line   string
+	}{
+		{"38694/1221", "38694", "1221"},
+		{"38694 / 1221", "38694", "1221"},
+		{"38694 - LN:1221", "38694", "1221"},
+		{"38694 LN:1221", "38694", "1221"},
+		{"38694-LN1221", "38694", "1221"},
+		{"5678", "5678", ""},
+		{" 5678 ", "5678", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		msn, line := ParseSerial(tt.serial)
+		if msn != tt.msn || line != tt.line {
+			t.Errorf("ParseSerial(%q) = %q, %q, want %q, %q", tt.serial, msn, line, tt.msn, tt.line)
+		}
+	}
+}
+
+func TestBuildDetails(t *testing.T) {
+	date := func(s string) time.Time {
+		t, _ := time.Parse("2006-01-02", s)
+		return t
+	}
+	now := date("2024-07-01")
+
+	sr := &ScrapeResult{
+		JetPhotos: &JetPhotosResult{Images: []ImageAttributes{
+			{DateTaken: "2024-05-01"},
+			{DateTaken: "2024-04-20", MSN: "38694", LineNumber: "1221"},
+		}},
+		FlightRadar: &FlightRadarResult{Flights: []*FlightAttributes{
+			{Date: "02 Jun 2024"},
+			{Date: "—"},
+		}},
+	}
+
+	tests := []struct {
+		name        string
+		sr          *ScrapeResult
+		searchDates []time.Time
+		first, last string
+		year        int
+		age         float64
+	}{
+		{
+			name:  "results only",
+			sr:    sr,
+			first: "2024-04-20",
+			last:  "2024-06-02",
+		},
+		{
+			name:        "search results",
+			sr:          sr,
+			searchDates: []time.Time{date("2024-05-30"), date("2012-07-01")},
+			first:       "2012-07-01",
+			last:        "2024-06-02",
+			year:        2012,
+			age:         12,
+		},
+		{
+			name: "nothing dated",
+			sr:   &ScrapeResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := BuildDetails(tt.sr, tt.searchDates, now)
+			if d.FirstSeen != tt.first || d.LastSeen != tt.last {
+				t.Errorf("seen %q to %q, want %q to %q", d.FirstSeen, d.LastSeen, tt.first, tt.last)
+			}
+			if tt.year == 0 {
+				if d.EstimatedDeliveryYear != nil || d.EstimatedAge != nil {
+					t.Errorf("estimated %v, %v without a photo date", d.EstimatedDeliveryYear, d.EstimatedAge)
+				}
+				return
+			}
+			if d.EstimatedDeliveryYear == nil || *d.EstimatedDeliveryYear != tt.year ||
+				d.EstimatedAge == nil || *d.EstimatedAge != tt.age {
+				t.Errorf("estimated %v, %v, want %d, %v", d.EstimatedDeliveryYear, d.EstimatedAge, tt.year, tt.age)
+			}
+		})
+	}
+
+	if d := BuildDetails(sr, nil, now); d.MSN != "38694" || d.LineNumber != "1221" {
+		t.Errorf("serial %q / %q", d.MSN, d.LineNumber)
+	}
+}
+
+func TestReadSearchDates(t *testing.T) {
+	tests := []struct {
+		file     string
+		dates    []string
+		lastPage int
+	}{
+		{"jp_search.html", []string{"2024-05-01", "2024-04-20", "2024-03-02"}, 7},
+		{"jp_search_last.html", []string{"2024-05-01", "2024-04-20", "2024-03-02"}, 1},
+		{"jp_search_nocaption.html", []string{"2024-05-01", "2024-03-02"}, 2},
+	}
+
+	for _, tt := range tests {
+		dates, lastPage := readSearchDates(openFixture(t, tt.file))
+		got := []string{}
+		for _, d := range dates {
+			got = append(got, d.Format("2006-01-02"))
+		}
+		if !reflect.DeepEqual(got, tt.dates) || lastPage != tt.lastPage {
+			t.Errorf("%s: dates %q, last page %d, want %q, %d", tt.file, got, lastPage, tt.dates, tt.lastPage)
+		}
+	}
+}
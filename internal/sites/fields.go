@@ -0,0 +1,163 @@
+package sites
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Fields is a set of dotted JSON paths rooted at ScrapeResult,
+// e.g. "JetPhotos.Images.Thumbnail". An empty set selects everything.
+type Fields [][]string
+
+func ParseFields(s string) (Fields, error) {
+	var fields Fields
+	if strings.TrimSpace(s) == "" {
+		return fields, nil
+	}
+
+	root := reflect.TypeOf(ScrapeResult{})
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		path := strings.Split(f, ".")
+		if !validFieldPath(root, path) {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		fields = append(fields, path)
+	}
+	return fields, nil
+}
+
+// Wants reports whether any part of the value at path is selected.
+func (f Fields) Wants(path ...string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, p := range f {
+		if hasPrefix(p, path) || hasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Names reports whether path, or a field below it, is listed itself
+// rather than taken in with a parent or an empty selection.
+func (f Fields) Names(path ...string) bool {
+	for _, p := range f {
+		if hasPrefix(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sub returns the selection below prefix, so it can be applied
+// to a JetPhotosResult or FlightRadarResult on its own.
+func (f Fields) Sub(prefix string) Fields {
+	if len(f) == 0 {
+		return nil
+	}
+	var sub Fields
+	for _, p := range f {
+		if p[0] != prefix {
+			continue
+		}
+		if len(p) == 1 {
+			return nil
+		}
+		sub = append(sub, p[1:])
+	}
+	if sub == nil {
+		// nothing below prefix was asked for, so select a path
+		// that matches no field
+		return Fields{{""}}
+	}
+	return sub
+}
+
+// Trim returns v with every unselected field removed.
+func (f Fields) Trim(v any) (any, error) {
+	if len(f) == 0 {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err = json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	return f.trim(generic, nil), nil
+}
+
+func (f Fields) trim(v any, path []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			childPath := append(path[:len(path):len(path)], k)
+			if !f.Wants(childPath...) {
+				delete(val, k)
+				continue
+			}
+			val[k] = f.trim(child, childPath)
+		}
+	case []any:
+		for i, child := range val {
+			val[i] = f.trim(child, path)
+		}
+	}
+	return v
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func validFieldPath(t reflect.Type, path []string) bool {
+	for _, name := range path {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		field, ok := jsonField(t, name)
+		if !ok {
+			return false
+		}
+		t = field.Type
+	}
+	return true
+}
+
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		if tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,130 @@
+package sites
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Fields
+		err  bool
+	}{
+		{"", nil, false},
+		{" , ", nil, false},
+		{"JetPhotos", Fields{{"JetPhotos"}}, false},
+		{"JetPhotos.Images.Thumbnail, FlightRadar.Flights",
+			Fields{{"JetPhotos", "Images", "Thumbnail"}, {"FlightRadar", "Flights"}}, false},
+		{"JetPhotos.Images.Nope", nil, true},
+		{"jetphotos", nil, true},
+		{"JetPhotos.Reg.Length", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFields(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseFields(%q) returned error %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFields(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFieldsWants(t *testing.T) {
+	f := Fields{{"JetPhotos", "Images", "Thumbnail"}}
+
+	tests := []struct {
+		fields Fields
+		path   []string
+		wants  bool
+		names  bool
+	}{
+		{nil, []string{"JetPhotos"}, true, false},
+		{f, []string{"JetPhotos"}, true, true},
+		{f, []string{"JetPhotos", "Images", "Thumbnail"}, true, true},
+		{f, []string{"JetPhotos", "Images", "Image"}, false, false},
+		{f, []string{"FlightRadar"}, false, false},
+		{Fields{{"JetPhotos"}}, []string{"JetPhotos", "Images", "Image"}, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.fields.Wants(tt.path...); got != tt.wants {
+			t.Errorf("%v.Wants(%v) = %v, want %v", tt.fields, tt.path, got, tt.wants)
+		}
+		if got := tt.fields.Names(tt.path...); got != tt.names {
+			t.Errorf("%v.Names(%v) = %v, want %v", tt.fields, tt.path, got, tt.names)
+		}
+	}
+}
+
+func TestFieldsSub(t *testing.T) {
+	tests := []struct {
+		fields Fields
+		prefix string
+		want   Fields
+	}{
+		{nil, "JetPhotos", nil},
+		{Fields{{"JetPhotos"}}, "JetPhotos", nil},
+		{Fields{{"JetPhotos", "Reg"}, {"FlightRadar"}}, "JetPhotos", Fields{{"Reg"}}},
+		{Fields{{"FlightRadar"}}, "JetPhotos", Fields{{""}}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.fields.Sub(tt.prefix); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v.Sub(%s) = %v, want %v", tt.fields, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestFieldsTrim(t *testing.T) {
+	result := &ScrapeResult{
+		JetPhotos: &JetPhotosResult{
+			Reg: "G-XLEA",
+			Images: []ImageAttributes{
+				{Link: "l1", Thumbnail: "t1", Photographer: "a"},
+				{Link: "l2", Thumbnail: "t2", Photographer: "b"},
+			},
+		},
+		FlightRadar: &FlightRadarResult{Operator: "BA"},
+	}
+
+	tests := []struct {
+		fields string
+		want   string
+	}{
+		{
+			fields: "JetPhotos.Images.Thumbnail",
+			want:   `{"JetPhotos":{"Images":[{"Thumbnail":"t1"},{"Thumbnail":"t2"}]}}`,
+		},
+		{
+			fields: "JetPhotos.Reg,FlightRadar.Operator",
+			want:   `{"FlightRadar":{"Operator":"BA"},"JetPhotos":{"Reg":"G-XLEA"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFields(tt.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trimmed, err := f.Trim(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(trimmed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("fields %s trimmed to %s, want %s", tt.fields, b, tt.want)
+		}
+	}
+
+	if v, _ := Fields(nil).Trim(result); v != any(result) {
+		t.Errorf("empty fields changed the result")
+	}
+}
@@ -0,0 +1,174 @@
+package sites
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type PhotoSort string
+
+const (
+	SortUploaded PhotoSort = "uploaded"
+	SortTaken    PhotoSort = "taken"
+	SortViews    PhotoSort = "views"
+)
+
+// values of the sort-order option on JetPhotos' advanced search
+var jpSortOrders = map[PhotoSort]int{
+	SortUploaded: 0,
+	SortTaken:    1,
+	SortViews:    2,
+}
+
+type PhotoFilters struct {
+	Location     string
+	Photographer string
+	Airline      string
+	TakenAfter   time.Time
+	TakenBefore  time.Time
+	Sort         PhotoSort
+}
+
+// AppliedFilters lists which filters JetPhotos handled in its search
+// and which were applied to the scraped images afterwards.
+type AppliedFilters struct {
+	Upstream []string `json:"Upstream"`
+	Local    []string `json:"Local"`
+}
+
+// ParseDateBound accepts a year, a month or a full date. With end set
+// the bound is moved to the end of the given period.
+func ParseDateBound(s string, end bool) (time.Time, error) {
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006", 1, 0, 0},
+		{"2006-01", 0, 1, 0},
+		{"2006-01-02", 0, 0, 1},
+	}
+	for _, l := range layouts {
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			continue
+		}
+		if end {
+			t = t.AddDate(l.years, l.months, l.days).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// ParseJPDate parses the dates shown on JetPhotos photo pages.
+func ParseJPDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	layouts := []string{
+		"2006-01-02",
+		"January 2, 2006",
+		"Jan 2, 2006",
+		"2 January 2006",
+		"2 Jan 2006",
+	}
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (f *PhotoFilters) Empty() bool {
+	return *f == PhotoFilters{}
+}
+
+// upstreamYear returns the year to pass to JetPhotos when both date
+// bounds fall within it.
+func (f *PhotoFilters) upstreamYear() (int, bool) {
+	if f.TakenAfter.IsZero() || f.TakenBefore.IsZero() {
+		return 0, false
+	}
+	if f.TakenAfter.Year() != f.TakenBefore.Year() {
+		return 0, false
+	}
+	return f.TakenAfter.Year(), true
+}
+
+func (f *PhotoFilters) applied() *AppliedFilters {
+	if f.Empty() {
+		return nil
+	}
+	a := &AppliedFilters{Upstream: []string{}}
+	if f.Sort != "" {
+		a.Upstream = append(a.Upstream, "sort")
+	}
+	if _, ok := f.upstreamYear(); ok {
+		a.Upstream = append(a.Upstream, "year")
+	}
+	a.Local = f.localFilters()
+	return a
+}
+
+func (f *PhotoFilters) localFilters() []string {
+	names := []string{}
+	if f.Location != "" {
+		names = append(names, "location")
+	}
+	if f.Photographer != "" {
+		names = append(names, "photographer")
+	}
+	if f.Airline != "" {
+		names = append(names, "airline")
+	}
+	if !f.TakenAfter.IsZero() {
+		names = append(names, "taken_after")
+	}
+	if !f.TakenBefore.IsZero() {
+		names = append(names, "taken_before")
+	}
+	return names
+}
+
+func (f *PhotoFilters) filterLocal(images []ImageAttributes) []ImageAttributes {
+	if len(f.localFilters()) == 0 {
+		return images
+	}
+	kept := []ImageAttributes{}
+	for _, image := range images {
+		if f.match(&image) {
+			kept = append(kept, image)
+		}
+	}
+	return kept
+}
+
+func (f *PhotoFilters) match(image *ImageAttributes) bool {
+	if !containsFold(image.Location, f.Location) ||
+		!containsFold(image.Photographer, f.Photographer) ||
+		!containsFold(image.Airline, f.Airline) {
+		return false
+	}
+
+	if f.TakenAfter.IsZero() && f.TakenBefore.IsZero() {
+		return true
+	}
+	taken, ok := ParseJPDate(image.DateTaken)
+	if !ok {
+		return false
+	}
+	if !f.TakenAfter.IsZero() && taken.Before(f.TakenAfter) {
+		return false
+	}
+	if !f.TakenBefore.IsZero() && taken.After(f.TakenBefore) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,131 @@
+package sites
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+)
+
+type FleetResult struct {
+	Airline  string                   `json:"Airline"`
+	Aircraft []*FleetAircraft         `json:"Aircraft"`
+	Enriched map[string]*ScrapeResult `json:"Enriched,omitempty"`
+	// next page of aircraft to enrich, if any are left
+	NextPage int `json:"NextPage,omitempty"`
+}
+
+type FleetAircraft struct {
+	Reg      string `json:"Reg"`
+	TypeCode string `json:"TypeCode"`
+}
+
+type FleetQueries struct {
+	// ICAO or IATA code, or the FR24 airline slug such as "ba-baw"
+	Airline string
+	// run each registration through Scrape with these queries
+	Enrich   bool
+	Aircraft *APIQueries
+	// only the aircraft on this page of Limit are enriched
+	Page  int
+	Limit int
+}
+
+const frAirlinesURL = "https://www.flightradar24.com/data/airlines"
+
+func ScrapeFleet(q *FleetQueries) (*FleetResult, error) {
+	slug, err := frAirlineSlug(q.Airline)
+	if err != nil {
+		return nil, err
+	}
+
+	URL := fmt.Sprintf("%s/%s/fleet", frAirlinesURL, slug)
+	b, err := scraper.FetchHTML(URL)
+	if err != nil {
+		return nil, frError("fetching fr fleet page", q.Airline, URL, err)
+	}
+
+	s := scraper.NewScraper(b)
+	defer s.Close()
+
+	response := &FleetResult{
+		Airline:  strings.ToUpper(q.Airline),
+		Aircraft: []*FleetAircraft{},
+	}
+
+	seen := map[string]bool{}
+	for {
+		regLink, err := s.ScrapeLinkPrefix("a", "/data/aircraft/")
+		if err != nil {
+			break
+		}
+		reg := strings.ToUpper(path.Base(regLink))
+
+		typeCode, err := s.ScrapeText("td", "", 1)
+		if err != nil {
+			break
+		}
+
+		if seen[reg] {
+			continue
+		}
+		seen[reg] = true
+		response.Aircraft = append(response.Aircraft, &FleetAircraft{
+			Reg:      reg,
+			TypeCode: strings.TrimSpace(typeCode[0]),
+		})
+	}
+
+	if len(response.Aircraft) == 0 {
+		return nil, frError("scraping fleet", q.Airline, URL, fmt.Errorf("no aircraft found"))
+	}
+
+	if !q.Enrich {
+		return response, nil
+	}
+
+	start := min((q.Page-1)*q.Limit, len(response.Aircraft))
+	end := min(start+q.Limit, len(response.Aircraft))
+	if end < len(response.Aircraft) {
+		response.NextPage = q.Page + 1
+	}
+
+	regs := []string{}
+	for _, aircraft := range response.Aircraft[start:end] {
+		regs = append(regs, aircraft.Reg)
+	}
+	response.Enriched, err = ScrapeMany(regs, q.Aircraft)
+	return response, err
+}
+
+// frAirlineSlug finds the "{iata}-{icao}" path FR24 uses for an
+// airline from either of its codes.
+func frAirlineSlug(airline string) (string, error) {
+	airline = strings.ToLower(airline)
+	if strings.Contains(airline, "-") {
+		return airline, nil
+	}
+
+	b, err := scraper.FetchHTML(frAirlinesURL)
+	if err != nil {
+		return "", frError("fetching fr airlines page", airline, frAirlinesURL, err)
+	}
+
+	s := scraper.NewScraper(b)
+	defer s.Close()
+
+	link, err := s.ScrapeLinkFunc("a", func(link string) bool {
+		slug, ok := strings.CutPrefix(link, "/data/airlines/")
+		if !ok {
+			return false
+		}
+		iata, icao, ok := strings.Cut(slug, "-")
+		return ok && (icao == airline || iata == airline)
+	})
+	if err != nil {
+		return "", frError("finding airline", airline, frAirlinesURL, err)
+	}
+
+	return path.Base(link), nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/macsencasaus/jetapi/internal/refdata"
 	"github.com/macsencasaus/jetapi/internal/scraper"
 )
 
@@ -16,6 +17,11 @@ type FlightRadarResult struct {
 	OperatorCode string              `json:"OperatorCode"`
 	ModeS        string              `json:"ModeS"`
 	Flights      []*FlightAttributes `json:"Flights"`
+	// reference data for TypeCode, when it is a known type
+	Type *refdata.AircraftType `json:"Type,omitempty"`
+	// reference data for AirlineCode and OperatorCode
+	AirlineInfo  *refdata.Airline `json:"AirlineInfo,omitempty"`
+	OperatorInfo *refdata.Airline `json:"OperatorInfo,omitempty"`
 }
 
 type FlightAttributes struct {
@@ -91,6 +97,16 @@ func ScrapeFlightRadar(q *APIQueries) (*FlightRadarResult, error) {
 		Flights:      []*FlightAttributes{},
 	}
 
+	if t, ok := refdata.LookupType(typeCode); ok {
+		response.Type = t
+	}
+	if a, ok := refdata.LookupAirline(airlineCode); ok {
+		response.AirlineInfo = a
+	}
+	if a, ok := refdata.LookupAirline(operatorCode); ok {
+		response.OperatorInfo = a
+	}
+
 	// flights
 	err = s.Advance("td", "w40 hidden-xs hidden-sm", 3)
 	if err != nil {
@@ -110,6 +126,26 @@ func ScrapeFlightRadar(q *APIQueries) (*FlightRadarResult, error) {
 }
 
 func scrapeFlight(s *scraper.Scraper) (*FlightAttributes, error) {
+	var flight string
+	f, err := scrapeFlightRow(s, func() error {
+		flightArr, err := s.ScrapeText("a", "fbold", 1)
+		if err != nil {
+			return err
+		}
+		flight = strings.TrimSpace(flightArr[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.Flight = flight
+	return f, nil
+}
+
+// scrapeFlightRow reads the next row of an FR24 flights table. middle
+// reads the cell between the route and the times, which holds the
+// flight number on aircraft pages and the aircraft on flight pages.
+func scrapeFlightRow(s *scraper.Scraper, middle func() error) (*FlightAttributes, error) {
 	// date
 	dateArr, err := s.ScrapeText("td", "hidden-xs hidden-sm", 1)
 	if err != nil {
@@ -130,12 +166,10 @@ func scrapeFlight(s *scraper.Scraper) (*FlightAttributes, error) {
 		return nil, err
 	}
 
-	// flight
-	flightArr, err := s.ScrapeText("a", "fbold", 1)
+	err = middle()
 	if err != nil {
 		return nil, err
 	}
-	flight := strings.TrimSpace(flightArr[0])
 
 	// time details
 	res, err := s.ScrapeText("td", "hidden-xs hidden-sm", 4)
@@ -158,7 +192,6 @@ func scrapeFlight(s *scraper.Scraper) (*FlightAttributes, error) {
 		Date:       date,
 		From:       from,
 		To:         to,
-		Flight:     flight,
 		FlightTime: flightTime,
 		STD:        std,
 		ATD:        atd,
@@ -0,0 +1,117 @@
+package sites
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+)
+
+type FlightNumberResult struct {
+	Flight   string                   `json:"Flight"`
+	Legs     []*FlightLeg             `json:"Legs"`
+	Aircraft map[string]*ScrapeResult `json:"Aircraft,omitempty"`
+}
+
+// FlightLeg is one operation of a flight number, in the shape of the
+// aircraft flight history plus the aircraft that flew it.
+type FlightLeg struct {
+	FlightAttributes
+	Reg string `json:"Reg"`
+}
+
+type FlightQueries struct {
+	Number  string
+	Flights int
+	// run each registration through Scrape with these queries
+	Chain    bool
+	Aircraft *APIQueries
+}
+
+const frFlightURL = "https://www.flightradar24.com/data/flights/"
+
+func ScrapeFlightNumber(q *FlightQueries) (*FlightNumberResult, error) {
+	number := strings.ToLower(q.Number)
+	URL := fmt.Sprintf("%s%s", frFlightURL, number)
+	b, err := scraper.FetchHTML(URL)
+	if err != nil {
+		return nil, frError("fetching fr flight page", number, URL, err)
+	}
+
+	s := scraper.NewScraper(b)
+	defer s.Close()
+
+	response, err := readFlightLegs(s, number, q.Flights)
+	if err != nil {
+		return nil, frError("finding flights", number, URL, err)
+	}
+
+	if !q.Chain {
+		return response, nil
+	}
+
+	err = chainAircraft(response, q.Aircraft)
+	return response, err
+}
+
+// readFlightLegs reads up to flights legs from the flight page of
+// number in s.
+func readFlightLegs(s *scraper.Scraper, number string, flights int) (*FlightNumberResult, error) {
+	response := &FlightNumberResult{
+		Flight: strings.ToUpper(number),
+		Legs:   []*FlightLeg{},
+	}
+
+	err := s.Advance("td", "w40 hidden-xs hidden-sm", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < flights; i++ {
+		leg, err := scrapeFlightLeg(s)
+		if err != nil {
+			break
+		}
+		leg.Flight = response.Flight
+		response.Legs = append(response.Legs, leg)
+	}
+	return response, nil
+}
+
+func scrapeFlightLeg(s *scraper.Scraper) (*FlightLeg, error) {
+	reg := ""
+	f, err := scrapeFlightRow(s, func() error {
+		// registration, taken from the aircraft link. Rows without one
+		// stop at the flight time cell rather than take the next row's.
+		regLink, ok, err := s.ScrapeLinkUntil("a", func(link string) bool {
+			return strings.HasPrefix(link, "/data/aircraft/")
+		}, "td", "hidden-xs hidden-sm")
+		if ok {
+			reg = strings.ToUpper(path.Base(regLink))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FlightLeg{FlightAttributes: *f, Reg: reg}, nil
+}
+
+// chainAircraft runs the full aircraft lookup for every registration
+// seen on the legs.
+func chainAircraft(response *FlightNumberResult, aq *APIQueries) error {
+	regs := []string{}
+	seen := map[string]bool{}
+	for _, leg := range response.Legs {
+		if leg.Reg == "" || seen[leg.Reg] {
+			continue
+		}
+		seen[leg.Reg] = true
+		regs = append(regs, leg.Reg)
+	}
+
+	var err error
+	response.Aircraft, err = ScrapeMany(regs, aq)
+	return err
+}
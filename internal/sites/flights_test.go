@@ -0,0 +1,108 @@
+package sites
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+)
+
+func openFixture(t *testing.T, name string) *scraper.Scraper {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := scraper.NewScraper(f)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestReadFlightLegs(t *testing.T) {
+	tests := []struct {
+		name    string
+		flights int
+		regs    []string
+	}{
+		{"all", 10, []string{"G-XLEA", "", "G-XLEB"}},
+		{"limited", 2, []string{"G-XLEA", ""}},
+		{"none", 0, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := readFlightLegs(openFixture(t, "fr_flight.html"), "ba1", tt.flights)
+			if err != nil {
+				t.Fatal(err)
+			}
+			regs := []string{}
+			for _, leg := range res.Legs {
+				regs = append(regs, leg.Reg)
+				if leg.Flight != "BA1" {
+					t.Errorf("leg of %s is flight %s", leg.Date, leg.Flight)
+				}
+			}
+			if !reflect.DeepEqual(regs, tt.regs) {
+				t.Errorf("regs = %q, want %q", regs, tt.regs)
+			}
+		})
+	}
+}
+
+func TestReadFlightLegsRow(t *testing.T) {
+	res, err := readFlightLegs(openFixture(t, "fr_flight.html"), "ba1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &FlightLeg{
+		FlightAttributes: FlightAttributes{
+			Date:       "01 May 2024",
+			From:       "London (LHR)",
+			To:         "New York (JFK)",
+			Flight:     "BA1",
+			FlightTime: "7:45",
+			STD:        "10:00",
+			ATD:        "10:12",
+			STA:        "13:00",
+			Status:     "Landed 12:58",
+		},
+		Reg: "G-XLEA",
+	}
+	if !reflect.DeepEqual(res.Legs[0], want) {
+		t.Errorf("leg = %+v, want %+v", res.Legs[0], want)
+	}
+}
+
+func TestScrapeFlight(t *testing.T) {
+	s := openFixture(t, "fr_aircraft.html")
+	if err := s.Advance("td", "w40 hidden-xs hidden-sm", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []FlightAttributes{
+		{
+			Date: "01 May 2024", From: "London (LHR)", To: "New York (JFK)", Flight: "BA1",
+			FlightTime: "7:45", STD: "10:00", ATD: "10:12", STA: "13:00", Status: "Landed 12:58",
+		},
+		{
+			Date: "01 May 2024", From: "New York (JFK)", To: "London (LHR)", Flight: "BA2",
+			FlightTime: "6:50", STD: "18:00", ATD: "18:20", STA: "06:10", Status: "Landed 06:02",
+		},
+	}
+
+	for i, want := range tests {
+		f, err := scrapeFlight(s)
+		if err != nil {
+			t.Fatalf("flight %d: %v", i, err)
+		}
+		if *f != want {
+			t.Errorf("flight %d = %+v, want %+v", i, *f, want)
+		}
+	}
+	if _, err := scrapeFlight(s); err == nil {
+		t.Error("read a flight past the last row")
+	}
+}
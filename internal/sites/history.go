@@ -0,0 +1,102 @@
+package sites
+
+import (
+	"sort"
+	"strings"
+)
+
+type HistoryResult struct {
+	Reg       string            `json:"Reg"`
+	Operators []*OperatorPeriod `json:"Operators"`
+	// every flight stored for the aircraft, filled in by the caller
+	Flights []*FlightAttributes `json:"Flights"`
+}
+
+// OperatorPeriod is a run of photos, in order of the date taken,
+// showing the aircraft with the same operator.
+type OperatorPeriod struct {
+	Operator   string          `json:"Operator"`
+	FirstPhoto string          `json:"FirstPhoto"`
+	LastPhoto  string          `json:"LastPhoto"`
+	Photos     int             `json:"Photos"`
+	Photo      ImageAttributes `json:"Photo"`
+}
+
+// ScrapeHistory walks up to photos of the aircraft's JetPhotos results
+// and builds its operator timeline, oldest first.
+func ScrapeHistory(reg string, photos int) (*HistoryResult, error) {
+	fields, err := ParseFields(
+		"JetPhotos.Images.Airline,JetPhotos.Images.DateTaken," +
+			"JetPhotos.Images.Photographer,JetPhotos.Images.Image")
+	if err != nil {
+		return nil, err
+	}
+
+	q := &APIQueries{
+		Reg:    reg,
+		Fields: fields,
+		Detail: DetailFull,
+	}
+
+	images := []ImageAttributes{}
+	for len(images) < photos {
+		q.Photos = photos - len(images)
+		res, err := ScrapeJetPhotos(q)
+		if res == nil {
+			if len(images) > 0 {
+				break
+			}
+			return nil, err
+		}
+		images = append(images, res.Images...)
+
+		if res.Next == "" {
+			break
+		}
+		q.Cursor, err = ParseCursor(res.Next)
+		if err != nil {
+			break
+		}
+	}
+
+	return &HistoryResult{
+		Reg:       strings.ToUpper(reg),
+		Operators: operatorTimeline(images),
+	}, nil
+}
+
+func operatorTimeline(images []ImageAttributes) []*OperatorPeriod {
+	type dated struct {
+		date  string
+		image ImageAttributes
+	}
+
+	photos := []dated{}
+	for _, image := range images {
+		t, ok := ParseJPDate(image.DateTaken)
+		if !ok || image.Airline == "" {
+			continue
+		}
+		photos = append(photos, dated{t.Format(detailsDateLayout), image})
+	}
+	sort.SliceStable(photos, func(i, j int) bool {
+		return photos[i].date < photos[j].date
+	})
+
+	periods := []*OperatorPeriod{}
+	var current *OperatorPeriod
+	for _, p := range photos {
+		if current == nil || normalizeName(current.Operator) != normalizeName(p.image.Airline) {
+			current = &OperatorPeriod{
+				Operator:   p.image.Airline,
+				FirstPhoto: p.date,
+			}
+			periods = append(periods, current)
+		}
+		current.LastPhoto = p.date
+		current.Photos++
+		// the latest photo best shows the livery the period ended in
+		current.Photo = p.image
+	}
+	return periods
+}
@@ -1,20 +1,35 @@
 package sites
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
-	"context"
 	"github.com/macsencasaus/jetapi/internal/scraper"
 	"golang.org/x/sync/errgroup"
 )
 
 type JetPhotosResult struct {
-	Reg    string            `json:"Reg"`
-	Images []ImageAttributes `json:"Images"`
+	Reg     string            `json:"Reg"`
+	Images  []ImageAttributes `json:"Images"`
+	Next    string            `json:"Next,omitempty"`
+	Filters *AppliedFilters   `json:"Filters,omitempty"`
 }
 
 type ImageAttributes struct {
+	// JetPhotos photo ID, the last part of Link
+	ID           string `json:"ID"`
+	Reg          string `json:"Reg"`
 	Image        string `json:"Image"`
 	Link         string `json:"Link"`
 	Thumbnail    string `json:"Thumbnail"`
@@ -25,123 +40,461 @@ type ImageAttributes struct {
 	Aircraft     string `json:"Aircraft"`
 	Serial       string `json:"Serial"`
 	Airline      string `json:"Airline"`
+
+	// link to the photographer endpoint of this API
+	PhotographerLink string `json:"PhotographerLink"`
+	// Serial split into its parts
+	MSN        string `json:"MSN"`
+	LineNumber string `json:"LineNumber"`
+	// perceptual hash of the thumbnail, with dedupe=true or when
+	// asked for in fields
+	Hash string `json:"Hash,omitempty"`
+	// near-identical photos collapsed into this one by dedupe=true
+	Duplicates []ImageAttributes `json:"Duplicates,omitempty"`
+
+	// JetPhotos profile of the photographer, from the photo page
+	profile string
 }
 
 const jpHomeURL = "https://www.jetphotos.com"
 
+// upper bound on search pages read in one request, reached when
+// local filters drop most of the results
+const jpMaxSearchPages = 5
+
+// upper bound on photo pages fetched at once
+const jpPageWorkers = 10
+
+const jpNextPageClass = "pagination__link pagination__link--next"
+
+// Cursor points at a result card in the JetPhotos search results.
+// Query ties it to the search it came from, since the same position
+// means another photo under a different registration or filters.
+type Cursor struct {
+	Page   int
+	Offset int
+	Query  string
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor: %v", err)
+	}
+	parts := strings.Split(string(b), ":")
+	if len(parts) != 3 || parts[2] == "" {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	c.Page, err = strconv.Atoi(parts[0])
+	if err != nil || c.Page < 1 {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	c.Offset, err = strconv.Atoi(parts[1])
+	if err != nil || c.Offset < 0 {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	c.Query = parts[2]
+	return c, nil
+}
+
+func (c Cursor) String() string {
+	if c.Page == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("%d:%d:%s", c.Page, c.Offset, c.Query)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// Matches reports whether c was handed out for the registration and
+// filters of q. Cursors built from a page number match any query.
+func (c Cursor) Matches(q *APIQueries) bool {
+	return c.Query == "" || c.Query == cursorQuery(q)
+}
+
+// cursorQuery sums up the parts of q that decide which photo a cursor
+// points at.
+func cursorQuery(q *APIQueries) string {
+	f := q.Filters
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.ToUpper(q.Reg),
+		f.Location,
+		f.Photographer,
+		f.Airline,
+		f.TakenAfter.Format(time.RFC3339),
+		f.TakenBefore.Format(time.RFC3339),
+		string(f.Sort),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// fields only found on the individual photo pages
+var jpDetailFields = []string{
+	"Reg",
+	"Image",
+	"DateTaken",
+	"DateUploaded",
+	"Location",
+	"Photographer",
+	"PhotographerLink",
+	"Aircraft",
+	"Serial",
+	"MSN",
+	"LineNumber",
+	"Airline",
+}
+
+func needsPhotoPages(q *APIQueries) bool {
+	if q.Detail == DetailNone {
+		return false
+	}
+	if (q.Fields.Wants("Profile") || q.Fields.Wants("Details")) && !q.OnlyJP {
+		return true
+	}
+	for _, field := range jpDetailFields {
+		if q.Fields.Wants("JetPhotos", "Images", field) {
+			return true
+		}
+	}
+	return false
+}
+
 func ScrapeJetPhotos(q *APIQueries) (*JetPhotosResult, error) {
 	reg := q.Reg
 	if q.Photos == 0 {
 		return &JetPhotosResult{Reg: strings.ToUpper(reg)}, nil
 	}
 
-	URL := fmt.Sprintf("%s/photo/keyword/%s", jpHomeURL, reg)
-	b, err := scraper.FetchHTML(URL)
-	if err != nil {
-		return nil, jpError("scraping search URL", reg, URL, err)
-	}
+	// local filters need the photo page fields unless the client
+	// settled for the result card captions
+	fetchPages := needsPhotoPages(q) ||
+		(q.Detail != DetailNone && len(q.Filters.localFilters()) > 0)
+	// result cards only carry a short caption, which is all we
+	// return when the photo pages are skipped
+	captions := !fetchPages
+	// each hash is another request, for the thumbnail, so they are
+	// only made for dedupe or when the fields name them
+	hashes := q.Dedupe || q.Fields.Names("JetPhotos", "Images", "Hash")
 
-	s := scraper.NewScraper(b)
-	defer s.Close()
+	images := []ImageAttributes{}
+	cursor := q.Cursor
+	if cursor.Page == 0 {
+		cursor.Page = 1
+	}
 
-	pageLinks := []string{}
-	thumbnails := []string{}
-	for i := 0; i < q.Photos; i++ {
-		pageLink, err := s.ScrapeLinks("a", "result__photoLink", 1)
+	var next Cursor
+	pageErrs := []error{}
+	for pages := 0; len(images) < q.Photos && pages < jpMaxSearchPages; pages++ {
+		URL := jpSearchURL(q, cursor.Page)
+		cards, n, err := scrapeSearchPage(reg, URL, cursor, q.Photos-len(images), &captions)
 		if err != nil {
-			if len(pageLinks) > 0 {
+			if len(images) > 0 {
+				// hand back the failed page so the client can retry it
+				next = cursor
 				break
 			}
-			return nil, jpError("scraping aircraft pagelinks", reg, URL, err)
+			return nil, err
 		}
 
-		thumbnail, err := s.ScrapeLinks("img", "result__photo", 1)
-		if err != nil {
-			if len(thumbnails) > 0 {
-				break
+		if fetchPages {
+			// one bad photo page shouldn't lose the rest
+			cards, err = scrapePhotoPages(reg, cards)
+			if err != nil {
+				pageErrs = append(pageErrs, err)
 			}
-			return nil, jpError("scraping aircraft thumbnails", reg, URL, err)
 		}
-		pageLinks = append(pageLinks, pageLink[0])
-		thumbnails = append(thumbnails, thumbnail[0])
+		linkPhotographers(cards)
+		cards = q.Filters.filterLocal(cards)
+
+		if hashes {
+			hashThumbnails(cards)
+		}
+		if q.Dedupe {
+			images = dedupeImages(images, cards)
+		} else {
+			images = append(images, cards...)
+		}
+
+		next = n
+		if next.Page == 0 {
+			break
+		}
+		cursor = next
 	}
 
-	images := make([]ImageAttributes, len(pageLinks))
+	next.Query = cursorQuery(q)
+	result := &JetPhotosResult{
+		Images:  images,
+		Reg:     strings.ToUpper(reg),
+		Next:    next.String(),
+		Filters: q.Filters.applied(),
+	}
 
-	pageScraper := func(i int, link string) error {
-		photoURL := fmt.Sprintf("%s%s", jpHomeURL, link)
-		images[i].Link = photoURL
-		images[i].Thumbnail = "https:" + thumbnails[i]
+	if len(images) == 0 && len(pageErrs) > 0 {
+		return nil, errors.Join(pageErrs...)
+	}
+	return result, errors.Join(pageErrs...)
+}
 
-		b, err := scraper.FetchHTML(photoURL)
-		if err != nil {
-			return jpError("fetching HTML page", reg, URL, err)
-		}
+// ScrapePhoto reads the JetPhotos page of the photo with id.
+func ScrapePhoto(id string) (*ImageAttributes, error) {
+	images := []ImageAttributes{{
+		ID:   id,
+		Link: fmt.Sprintf("%s/photo/%s", jpHomeURL, id),
+	}}
+	err := scrapePhotoPage(id, &images[0])
+	if err != nil {
+		return nil, err
+	}
+	linkPhotographers(images)
+	return &images[0], nil
+}
 
-		s := scraper.NewScraper(b)
-		defer s.Close()
+// scrapePhotoPages fills in images from their photo pages. Images whose
+// page can't be read are left out, and their errors joined.
+func scrapePhotoPages(reg string, images []ImageAttributes) ([]ImageAttributes, error) {
+	errs := make([]error, len(images))
 
-		// photo links
-		photoLinkArr, err := s.ScrapeLinks("img", "large-photo__img", 1)
-		if err != nil {
-			return jpError("scraping photo links", reg, URL, err)
-		}
-		images[i].Image = photoLinkArr[0]
+	g, _ := errgroup.WithContext(context.Background())
+	g.SetLimit(jpPageWorkers)
 
-		// registration + dates
-		res, err := s.ScrapeText("h4", "headerText4 color-shark", 3)
-		if err != nil {
-			return jpError("scraping registrating text", reg, URL, err)
+	for i := range images {
+		g.Go(func() error {
+			errs[i] = scrapePhotoPage(reg, &images[i])
+			return nil
+		})
+	}
+	g.Wait()
+
+	scraped := []ImageAttributes{}
+	for i, image := range images {
+		if errs[i] == nil {
+			scraped = append(scraped, image)
 		}
-		images[i].DateTaken = res[1]
-		images[i].DateUploaded = res[2]
+	}
+	return scraped, errors.Join(errs...)
+}
 
-		// aircraft
-		s.Advance("h2", "header-reset", 1)
-		res, err = s.ScrapeText("a", "link", 3)
+func scrapePhotoPage(reg string, image *ImageAttributes) error {
+	photoURL := image.Link
+	b, err := scraper.FetchHTML(photoURL)
+	if err != nil {
+		return jpError("fetching HTML page", reg, photoURL, err)
+	}
+	page, err := io.ReadAll(b)
+	b.Close()
+	if err != nil {
+		return jpError("reading HTML page", reg, photoURL, err)
+	}
+
+	err = readPhotoPage(scraper.NewScraper(io.NopCloser(bytes.NewReader(page))), reg, photoURL, image)
+	if err != nil {
+		return err
+	}
+
+	// the profile link isn't in a fixed place among the fields above,
+	// so it is looked for in a pass of its own
+	s := scraper.NewScraper(io.NopCloser(bytes.NewReader(page)))
+	link, err := s.ScrapeLinkPrefix("a", "/photographer/")
+	if err == nil {
+		image.profile = jpHomeURL + link
+	}
+	return nil
+}
+
+// readPhotoPage fills in image from the photo page at photoURL in s.
+func readPhotoPage(s *scraper.Scraper, reg, photoURL string, image *ImageAttributes) error {
+	defer s.Close()
+
+	// photo links
+	photoLinkArr, err := s.ScrapeLinks("img", "large-photo__img", 1)
+	if err != nil {
+		return jpError("scraping photo links", reg, photoURL, err)
+	}
+	image.Image = photoLinkArr[0]
+
+	// registration + dates
+	res, err := s.ScrapeText("h4", "headerText4 color-shark", 3)
+	if err != nil {
+		return jpError("scraping registrating text", reg, photoURL, err)
+	}
+	image.Reg = strings.TrimSpace(res[0])
+	image.DateTaken = res[1]
+	image.DateUploaded = res[2]
+
+	// aircraft
+	s.Advance("h2", "header-reset", 1)
+	res, err = s.ScrapeText("a", "link", 3)
+	if err != nil {
+		return jpError("scraping aircraft text", reg, photoURL, err)
+	}
+	image.Aircraft = res[0]
+	image.Airline = res[1]
+	image.Serial = strings.TrimSpace(res[2])
+	image.MSN, image.LineNumber = ParseSerial(image.Serial)
+
+	// location
+	s.Advance("h5", "header-reset", 1)
+	location, err := s.ScrapeText("a", "link", 1)
+	if err != nil {
+		return jpError("scraping location text", reg, photoURL, err)
+	}
+	image.Location = location[0]
+
+	// photographer
+	photographer, err := s.ScrapeText("h6", "header-reset", 1)
+	if err != nil {
+		return jpError("scraping photographer text", reg, photoURL, err)
+	}
+	image.Photographer = photographer[0]
+
+	return nil
+}
+
+// scrapeSearchPage reads up to want result cards from the search page at URL,
+// skipping the first c.Offset. The returned cursor points at the
+// next unread card and is zero once the last page has been read.
+func scrapeSearchPage(
+	reg, URL string,
+	c Cursor,
+	want int,
+	captions *bool,
+) ([]ImageAttributes, Cursor, error) {
+	b, err := scraper.FetchHTML(URL)
+	if err != nil {
+		return nil, Cursor{}, jpError("scraping search URL", reg, URL, err)
+	}
+
+	s := scraper.NewScraper(b)
+	defer s.Close()
+
+	return readSearchPage(s, reg, URL, c, want, captions)
+}
+
+// readSearchPage reads the result cards of the search page at URL
+// from s.
+func readSearchPage(
+	s *scraper.Scraper,
+	reg, URL string,
+	c Cursor,
+	want int,
+	captions *bool,
+) ([]ImageAttributes, Cursor, error) {
+	if c.Offset > 0 {
+		err := s.Advance("a", "result__photoLink", c.Offset)
 		if err != nil {
-			return jpError("scraping aircraft text", reg, URL, err)
+			return nil, Cursor{}, jpError("skipping to cursor", reg, URL, err)
 		}
-		images[i].Aircraft = res[0]
-		images[i].Airline = res[1]
-		images[i].Serial = strings.TrimSpace(res[2])
+	}
 
-		// location
-		s.Advance("h5", "header-reset", 1)
-		location, err := s.ScrapeText("a", "link", 1)
+	images := []ImageAttributes{}
+	for i := 0; i < want; i++ {
+		pageLink, err := s.ScrapeLinks("a", "result__photoLink", 1)
 		if err != nil {
-			return jpError("scraping location text", reg, URL, err)
+			if len(images) > 0 {
+				break
+			}
+			return nil, Cursor{}, jpError("scraping aircraft pagelinks", reg, URL, err)
 		}
-		images[i].Location = location[0]
 
-		// photographer
-		photographer, err := s.ScrapeText("h6", "header-reset", 1)
+		thumbnail, err := s.ScrapeLinks("img", "result__photo", 1)
 		if err != nil {
-			return jpError("scraping photographer text", reg, URL, err)
+			if len(images) > 0 {
+				break
+			}
+			return nil, Cursor{}, jpError("scraping aircraft thumbnails", reg, URL, err)
+		}
+		image := ImageAttributes{
+			ID:        path.Base(pageLink[0]),
+			Link:      fmt.Sprintf("%s%s", jpHomeURL, pageLink[0]),
+			Thumbnail: "https:" + thumbnail[0],
 		}
-		images[i].Photographer = photographer[0]
+		if *captions {
+			// a card missing its caption likely means the layout has
+			// changed, so stop looking for the rest
+			*captions = scrapeResultCaption(s, &image)
+		}
+		images = append(images, image)
+	}
 
-		return nil
+	if len(images) == want {
+		err := s.Advance("a", "result__photoLink", 1)
+		if err == nil {
+			next := Cursor{Page: c.Page, Offset: c.Offset + want}
+			return images, next, nil
+		}
 	}
 
-	g, _ := errgroup.WithContext(context.Background())
+	var next Cursor
+	err := s.Advance("a", jpNextPageClass, 1)
+	if err == nil {
+		next = Cursor{Page: c.Page + 1}
+	}
+	return images, next, nil
+}
 
-	for i, link := range pageLinks {
-		g.Go(func() error {
-			return pageScraper(i, link)
-		})
+func jpSearchURL(q *APIQueries, page int) string {
+	f := &q.Filters
+	year, byYear := f.upstreamYear()
+	if f.Sort == "" && !byYear {
+		URL := fmt.Sprintf("%s/photo/keyword/%s", jpHomeURL, q.Reg)
+		if page > 1 {
+			URL = fmt.Sprintf("%s?page=%d", URL, page)
+		}
+		return URL
 	}
 
-	if err = g.Wait(); err != nil {
-		return nil, err
+	// the keyword page has no options, use the advanced search instead
+	return jpAdvancedSearchURL("reg", q.Reg, jpSortOrders[f.Sort], year, page)
+}
+
+// jpAdvancedSearchURL builds a showphotos.php search, a year of 0
+// searches all years.
+func jpAdvancedSearchURL(keywordsType, keywords string, sort, year, page int) string {
+	params := url.Values{}
+	params.Set("search-type", "Advanced")
+	params.Set("keywords-type", keywordsType)
+	params.Set("keywords-contain", "3")
+	params.Set("keywords", keywords)
+	params.Set("sort-order", strconv.Itoa(sort))
+	params.Set("photo-year", "all")
+	if year != 0 {
+		params.Set("photo-year", strconv.Itoa(year))
 	}
+	params.Set("page", strconv.Itoa(page))
+	return fmt.Sprintf("%s/showphotos.php?%s", jpHomeURL, params.Encode())
+}
 
-	result := &JetPhotosResult{
-		Images: images,
-		Reg:    strings.ToUpper(reg),
+func scrapeResultCaption(s *scraper.Scraper, image *ImageAttributes) bool {
+	fields := []struct {
+		class string
+		value *string
+	}{
+		{"result__infoListText result__infoListText--aircraft", &image.Aircraft},
+		{"result__infoListText result__infoListText--airline", &image.Airline},
+		{"result__infoListText result__infoListText--date", &image.DateTaken},
+		{"result__infoListText result__infoListText--location", &image.Location},
+		{"result__infoListText result__infoListText--photographer", &image.Photographer},
 	}
 
-	return result, nil
+	for _, field := range fields {
+		// the caption ends where the next card's photo link starts
+		found, err := s.AdvanceUntil("span", field.class, "a", "result__photoLink")
+		if err != nil || !found {
+			return false
+		}
+		// either plain text or a link, like the FR airline
+		text, ok := s.TryScrapeText()
+		if !ok {
+			res, err := s.ScrapeText("a", "", 1)
+			if err != nil {
+				return false
+			}
+			text = res[0]
+		}
+		*field.value = strings.TrimSpace(text)
+	}
+	return true
 }
 
 func jpError(msg, reg, url string, err error) error {
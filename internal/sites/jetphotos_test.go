@@ -0,0 +1,157 @@
+package sites
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCursor(t *testing.T) {
+	enc := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		s    string
+		want Cursor
+		err  bool
+	}{
+		{enc("2:5:abc"), Cursor{Page: 2, Offset: 5, Query: "abc"}, false},
+		{enc("1:0:abc"), Cursor{Page: 1, Offset: 0, Query: "abc"}, false},
+		{"", Cursor{}, true},
+		{"not base64!", Cursor{}, true},
+		{enc("2:5"), Cursor{}, true},
+		{enc("2:5:"), Cursor{}, true},
+		{enc("0:5:abc"), Cursor{}, true},
+		{enc("2:-1:abc"), Cursor{}, true},
+		{enc("x:5:abc"), Cursor{}, true},
+		{enc("2:5:abc:def"), Cursor{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCursor(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseCursor(%q) returned error %v", tt.s, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCursor(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+		if err == nil && got.String() != tt.s {
+			t.Errorf("ParseCursor(%q).String() = %q", tt.s, got.String())
+		}
+	}
+
+	if s := (Cursor{}).String(); s != "" {
+		t.Errorf("zero cursor encodes to %q", s)
+	}
+}
+
+func TestCursorMatches(t *testing.T) {
+	q := &APIQueries{Reg: "G-XLEA", Filters: PhotoFilters{Airline: "British Airways"}}
+	c := Cursor{Page: 2, Offset: 3, Query: cursorQuery(q)}
+
+	tests := []struct {
+		name string
+		c    Cursor
+		q    *APIQueries
+		want bool
+	}{
+		{"same query", c, q, true},
+		{"registration in another case", c, &APIQueries{Reg: "g-xlea", Filters: q.Filters}, true},
+		{"other registration", c, &APIQueries{Reg: "G-XLEB", Filters: q.Filters}, false},
+		{"other filters", c, &APIQueries{Reg: "G-XLEA"}, false},
+		{"other date range", c, &APIQueries{Reg: "G-XLEA", Filters: PhotoFilters{
+			Airline:    "British Airways",
+			TakenAfter: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		}}, false},
+		{"from a page number", Cursor{Page: 2}, &APIQueries{Reg: "G-XLEB"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.c.Matches(tt.q); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadSearchPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		cursor   Cursor
+		want     int
+		ids      []string
+		next     Cursor
+		captions bool
+	}{
+		{"first cards", "jp_search.html", Cursor{Page: 1}, 2, []string{"11111111", "22222222"}, Cursor{Page: 1, Offset: 2}, true},
+		{"rest of the page", "jp_search.html", Cursor{Page: 1, Offset: 2}, 5, []string{"33333333"}, Cursor{Page: 2}, true},
+		{"whole page", "jp_search.html", Cursor{Page: 1}, 3, []string{"11111111", "22222222", "33333333"}, Cursor{Page: 2}, true},
+		{"last page", "jp_search_last.html", Cursor{Page: 3}, 5, []string{"11111111", "22222222", "33333333"}, Cursor{}, true},
+		{"card without a caption", "jp_search_nocaption.html", Cursor{Page: 1}, 3, []string{"11111111", "22222222", "33333333"}, Cursor{Page: 2}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captions := true
+			images, next, err := readSearchPage(openFixture(t, tt.file), "G-XLEA", tt.file, tt.cursor, tt.want, &captions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(images) != len(tt.ids) {
+				t.Fatalf("got %d images, want %d", len(images), len(tt.ids))
+			}
+			for i, image := range images {
+				if image.ID != tt.ids[i] {
+					t.Errorf("image %d is %s, want %s", i, image.ID, tt.ids[i])
+				}
+				if image.Link != jpHomeURL+"/photo/"+tt.ids[i] {
+					t.Errorf("image %d links to %s", i, image.Link)
+				}
+				if image.Thumbnail != "https://cdn.jetphotos.com/400/6/"+tt.ids[i]+".jpg" {
+					t.Errorf("image %d has thumbnail %s", i, image.Thumbnail)
+				}
+			}
+			if next != tt.next {
+				t.Errorf("next = %+v, want %+v", next, tt.next)
+			}
+			if captions != tt.captions {
+				t.Errorf("captions = %v, want %v", captions, tt.captions)
+			}
+		})
+	}
+}
+
+func TestReadSearchPageCaptions(t *testing.T) {
+	captions := true
+	images, _, err := readSearchPage(openFixture(t, "jp_search.html"), "G-XLEA", "jp_search.html", Cursor{Page: 1}, 2, &captions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ImageAttributes{
+		{
+			Aircraft:     "Airbus A380-841",
+			Airline:      "British Airways",
+			DateTaken:    "2024-05-01",
+			Location:     "London Heathrow Airport (LHR / EGLL)",
+			Photographer: "Jane Doe",
+		},
+		{
+			Aircraft:     "Airbus A380-841",
+			Airline:      "British Airways",
+			DateTaken:    "2024-04-20",
+			Location:     "Los Angeles International Airport (LAX / KLAX)",
+			Photographer: "John Roe",
+		},
+	}
+	for i, w := range want {
+		got := images[i]
+		got.ID, got.Link, got.Thumbnail = "", "", ""
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("image %d caption = %+v, want %+v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package sites
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/macsencasaus/jetapi/internal/scraper"
+)
+
+type PhotographerResult struct {
+	Name       string            `json:"Name"`
+	Profile    string            `json:"Profile"`
+	Location   string            `json:"Location"`
+	PhotoCount int               `json:"PhotoCount"`
+	Images     []ImageAttributes `json:"Images"`
+}
+
+const photographerAPIPath = "/api/photographer"
+
+var ErrNoPhotographer = errors.New("no photos by this photographer")
+
+// ScrapePhotographer returns the profile and newest photos of the
+// photographer name, or ErrNoPhotographer if no photos are theirs.
+func ScrapePhotographer(name string, photos int) (*PhotographerResult, error) {
+	result := &PhotographerResult{Name: name, Images: []ImageAttributes{}}
+	if photos == 0 {
+		return result, nil
+	}
+
+	URL := jpAdvancedSearchURL("photographer", name, jpSortOrders[SortUploaded], 0, 1)
+	captions := false
+	images, _, err := scrapeSearchPage(name, URL, Cursor{Page: 1}, photos, &captions)
+	if err != nil {
+		return nil, err
+	}
+
+	images, pageErr := scrapePhotoPages(name, images)
+	// the search also matches other names containing name
+	for _, image := range images {
+		if strings.EqualFold(strings.TrimSpace(image.Photographer), strings.TrimSpace(name)) {
+			result.Images = append(result.Images, image)
+		}
+	}
+	if len(result.Images) == 0 {
+		return nil, errors.Join(ErrNoPhotographer, pageErr)
+	}
+	linkPhotographers(result.Images)
+
+	err = scrapePhotographerProfile(result, result.Images[0].profile)
+	return result, errors.Join(pageErr, err)
+}
+
+// scrapePhotographerProfile reads the profile page linked from a photo
+// page. Errors leave the profile fields empty.
+func scrapePhotographerProfile(result *PhotographerResult, profileURL string) error {
+	if profileURL == "" {
+		return fmt.Errorf("no profile link for %s", result.Name)
+	}
+	result.Profile = profileURL
+
+	b, err := scraper.FetchHTML(result.Profile)
+	if err != nil {
+		return jpError("fetching profile page", result.Name, result.Profile, err)
+	}
+
+	s := scraper.NewScraper(b)
+	defer s.Close()
+
+	location, err := s.ScrapeText("span", "profile__location", 1)
+	if err == nil {
+		result.Location = strings.TrimSpace(location[0])
+	}
+
+	count, err := s.ScrapeText("span", "profile__statValue", 1)
+	if err != nil {
+		return jpError("scraping photo count", result.Name, result.Profile, err)
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(strings.TrimSpace(count[0]), ",", ""))
+	if err != nil {
+		return jpError("parsing photo count", result.Name, result.Profile, err)
+	}
+	result.PhotoCount = n
+
+	return nil
+}
+
+func linkPhotographers(images []ImageAttributes) {
+	for i := range images {
+		if images[i].Photographer == "" {
+			continue
+		}
+		images[i].PhotographerLink = fmt.Sprintf("%s?name=%s",
+			photographerAPIPath, url.QueryEscape(images[i].Photographer))
+	}
+}
@@ -0,0 +1,164 @@
+package sites
+
+import (
+	"regexp"
+	"strings"
+)
+
+// AircraftProfile reconciles the fields JetPhotos and FlightRadar24
+// both report into one record.
+type AircraftProfile struct {
+	Reg      *ProfileField `json:"Reg"`
+	Aircraft *ProfileField `json:"Aircraft"`
+	Airline  *ProfileField `json:"Airline"`
+}
+
+type ProfileField struct {
+	Value      string `json:"Value"`
+	Confidence string `json:"Confidence"`
+	// reports agreeing with Value
+	Sources []Provenance `json:"Sources"`
+	// reports of a different value
+	Conflicts []Provenance `json:"Conflicts"`
+}
+
+type Provenance struct {
+	Source string `json:"Source"`
+	// link of the JetPhotos photo the value was read from
+	Photo string `json:"Photo,omitempty"`
+	Value string `json:"Value"`
+}
+
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+const (
+	sourceJP = "JetPhotos"
+	sourceFR = "FlightRadar"
+)
+
+var (
+	// "777-36N" -> "777-300", Boeing customer codes
+	boeingVariant = regexp.MustCompile(`\b(7[0-9]7)-([0-9])[0-9A-Z]{2}\b`)
+	// "A320-251N" -> "A320-200N"
+	airbusVariant = regexp.MustCompile(`\b(A3[0-9]{2})-([0-9])[0-9]{2}(N?)\b`)
+)
+
+func BuildProfile(sr *ScrapeResult) *AircraftProfile {
+	var reg, aircraft, airline []Provenance
+
+	// FlightRadar is listed first so that it wins ties, as it reflects
+	// the aircraft's current state
+	if fr := sr.FlightRadar; fr != nil {
+		if fr.Aircraft != "" {
+			aircraft = append(aircraft, Provenance{Source: sourceFR, Value: fr.Aircraft})
+		}
+		if fr.Airline != "" {
+			airline = append(airline, Provenance{Source: sourceFR, Value: fr.Airline})
+		}
+	}
+
+	if jp := sr.JetPhotos; jp != nil {
+		// images are newest first
+		for _, image := range jp.Images {
+			if image.Reg != "" {
+				reg = append(reg, Provenance{
+					Source: sourceJP, Photo: image.Link, Value: image.Reg,
+				})
+			}
+			if image.Aircraft != "" {
+				aircraft = append(aircraft, Provenance{
+					Source: sourceJP, Photo: image.Link, Value: image.Aircraft,
+				})
+			}
+			if image.Airline != "" {
+				airline = append(airline, Provenance{
+					Source: sourceJP, Photo: image.Link, Value: image.Airline,
+				})
+			}
+		}
+	}
+
+	return &AircraftProfile{
+		Reg:      reconcile(reg, normalizeReg),
+		Aircraft: reconcile(aircraft, normalizeAircraft),
+		Airline:  reconcile(airline, normalizeName),
+	}
+}
+
+// reconcile groups the reports by their normalized value and picks the
+// group with the most weight, FlightRadar counting twice.
+func reconcile(reports []Provenance, normalize func(string) string) *ProfileField {
+	field := &ProfileField{
+		Confidence: ConfidenceLow,
+		Sources:    []Provenance{},
+		Conflicts:  []Provenance{},
+	}
+	if len(reports) == 0 {
+		return field
+	}
+
+	weights := map[string]int{}
+	order := []string{}
+	total := 0
+	for _, r := range reports {
+		key := normalize(r.Value)
+		if _, ok := weights[key]; !ok {
+			order = append(order, key)
+		}
+		w := 1
+		if r.Source == sourceFR {
+			w = 2
+		}
+		weights[key] += w
+		total += w
+	}
+
+	best := order[0]
+	for _, key := range order[1:] {
+		if weights[key] > weights[best] {
+			best = key
+		}
+	}
+
+	for _, r := range reports {
+		if normalize(r.Value) == best {
+			if field.Value == "" {
+				field.Value = r.Value
+			}
+			field.Sources = append(field.Sources, r)
+		} else {
+			field.Conflicts = append(field.Conflicts, r)
+		}
+	}
+
+	switch {
+	case len(field.Sources) < 2:
+		field.Confidence = ConfidenceLow
+	case len(field.Conflicts) == 0:
+		field.Confidence = ConfidenceHigh
+	case 2*weights[best] > total:
+		field.Confidence = ConfidenceMedium
+	}
+	return field
+}
+
+func normalizeReg(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+func normalizeName(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func normalizeAircraft(s string) string {
+	s = strings.ToUpper(s)
+	s = boeingVariant.ReplaceAllString(s, "${1}-${2}00")
+	s = airbusVariant.ReplaceAllString(s, "${1}-${2}00${3}")
+	// "777-300(ER)" -> "777-300ER"
+	s = strings.NewReplacer("(", "", ")", "").Replace(s)
+	return normalizeName(s)
+}
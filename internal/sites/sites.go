@@ -3,20 +3,36 @@ package sites
 import (
 	"context"
 	"fmt"
+	"time"
+
 	"golang.org/x/sync/errgroup"
 )
 
 type ScrapeResult struct {
 	JetPhotos   *JetPhotosResult
 	FlightRadar *FlightRadarResult
+	Profile     *AircraftProfile
+	Details     *AircraftDetails
 }
 
+type DetailLevel string
+
+const (
+	DetailFull DetailLevel = "full"
+	DetailNone DetailLevel = "none"
+)
+
 type APIQueries struct {
 	Reg     string
 	Photos  int
 	Flights int
 	OnlyJP  bool
 	OnlyFR  bool
+	Fields  Fields
+	Detail  DetailLevel
+	Cursor  Cursor
+	Filters PhotoFilters
+	Dedupe  bool
 }
 
 func Scrape(q *APIQueries) (*ScrapeResult, error) {
@@ -27,23 +43,46 @@ func Scrape(q *APIQueries) (*ScrapeResult, error) {
 
 	g, _ := errgroup.WithContext(context.Background())
 
-	g.Go(func() error {
-		res, err := ScrapeJetPhotos(q)
-		if err != nil {
-			return fmt.Errorf("JetPhotos Error: %v", err)
-		}
-		jpResult = res
-		return nil
-	})
-
-	g.Go(func() error {
-		res, err := ScrapeFlightRadar(q)
-		if err != nil {
-			return fmt.Errorf("FlightRadar Error: %v", err)
-		}
-		frResult = res
-		return nil
-	})
+	profile := q.Fields.Wants("Profile")
+	details := q.Fields.Wants("Details")
+	// both are built from the two sources
+	derived := profile || details
+
+	if q.Fields.Wants("JetPhotos") || derived {
+		g.Go(func() error {
+			res, err := ScrapeJetPhotos(q)
+			// photos whose page failed are left out of res
+			jpResult = res
+			if err != nil {
+				return fmt.Errorf("JetPhotos Error: %v", err)
+			}
+			return nil
+		})
+	}
+
+	if q.Fields.Wants("FlightRadar") || derived {
+		g.Go(func() error {
+			res, err := ScrapeFlightRadar(q)
+			if err != nil {
+				return fmt.Errorf("FlightRadar Error: %v", err)
+			}
+			frResult = res
+			return nil
+		})
+	}
+
+	// the first and last photos of the aircraft, beyond those returned
+	var searchDates []time.Time
+	if details && q.Photos != 0 {
+		g.Go(func() error {
+			dates, err := scrapeSearchDates(q.Reg)
+			searchDates = dates
+			if err != nil {
+				return fmt.Errorf("JetPhotos Error: %v", err)
+			}
+			return nil
+		})
+	}
 
 	err := g.Wait()
 
@@ -51,5 +90,47 @@ func Scrape(q *APIQueries) (*ScrapeResult, error) {
 		return nil, err
 	}
 
-	return &ScrapeResult{JetPhotos: jpResult, FlightRadar: frResult}, err
+	result := &ScrapeResult{JetPhotos: jpResult, FlightRadar: frResult}
+	if profile {
+		result.Profile = BuildProfile(result)
+	}
+	if details {
+		result.Details = BuildDetails(result, searchDates, time.Now())
+	}
+	return result, err
+}
+
+// upper bound on aircraft scraped at once by ScrapeMany
+const scrapeWorkers = 4
+
+// ScrapeMany runs Scrape for each registration with the other queries
+// taken from q. Registrations that fail completely are left out.
+func ScrapeMany(regs []string, q *APIQueries) (map[string]*ScrapeResult, error) {
+	results := make([]*ScrapeResult, len(regs))
+
+	g, _ := errgroup.WithContext(context.Background())
+	g.SetLimit(scrapeWorkers)
+
+	for i, reg := range regs {
+		g.Go(func() error {
+			rq := *q
+			rq.Reg = reg
+			res, err := Scrape(&rq)
+			results[i] = res
+			if err != nil {
+				return fmt.Errorf("%s: %v", reg, err)
+			}
+			return nil
+		})
+	}
+
+	err := g.Wait()
+
+	byReg := map[string]*ScrapeResult{}
+	for i, reg := range regs {
+		if results[i] != nil {
+			byReg[reg] = results[i]
+		}
+	}
+	return byReg, err
 }
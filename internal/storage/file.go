@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+// FileStore keeps each aircraft in its own directory, with snapshots
+// appended to a JSON lines file and the merged flight log beside it.
+// The newest snapshot is also kept on its own, so Latest doesn't read
+// the whole history.
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+const (
+	snapshotsFile = "snapshots.jsonl"
+	// older snapshots, replaced each time snapshotsFile is rotated
+	rotatedFile   = "snapshots.1.jsonl"
+	latestFile    = "latest.json"
+	canonicalFile = "canonical.json"
+	flightsFile   = "flights.json"
+)
+
+// size at which snapshotsFile is rotated, so each aircraft keeps at
+// most twice this of snapshots
+const maxSnapshotsBytes = 8 << 20
+
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("creating storage directory: %v", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (fs *FileStore) Save(s *Snapshot) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	dir, err := fs.regDir(s.Reg)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	err = rotate(dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, snapshotsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	err = WriteFileAtomic(filepath.Join(dir, latestFile), s)
+	if err != nil {
+		return err
+	}
+	if s.Canonical {
+		err = WriteFileAtomic(filepath.Join(dir, canonicalFile), s)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.FlightRadar == nil || len(s.FlightRadar.Flights) == 0 {
+		return nil
+	}
+	log, err := fs.readFlights(dir)
+	if err != nil {
+		return err
+	}
+	log = mergeFlights(log, s.FlightRadar.Flights)
+	return WriteFileAtomic(filepath.Join(dir, flightsFile), log)
+}
+
+func (fs *FileStore) Latest(reg string) (*Snapshot, error) {
+	return fs.readSnapshot(reg, latestFile)
+}
+
+func (fs *FileStore) LatestCanonical(reg string) (*Snapshot, error) {
+	return fs.readSnapshot(reg, canonicalFile)
+}
+
+// readSnapshot reads the single snapshot kept in the file name.
+func (fs *FileStore) readSnapshot(reg, name string) (*Snapshot, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir, err := fs.regDir(reg)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{}
+	err = json.Unmarshal(b, s)
+	return s, err
+}
+
+func (fs *FileStore) Snapshots(reg string) ([]*Snapshot, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir, err := fs.regDir(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*Snapshot{}
+	for _, name := range []string{rotatedFile, snapshotsFile} {
+		snapshots, err = readSnapshots(filepath.Join(dir, name), snapshots)
+		if err != nil {
+			return nil, fmt.Errorf("reading snapshots of %s: %v", reg, err)
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil, ErrNotFound
+	}
+	return snapshots, nil
+}
+
+// readSnapshots appends the snapshots in the file at path to
+// snapshots. A missing file has none.
+func readSnapshots(path string, snapshots []*Snapshot) ([]*Snapshot, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// snapshots with many photos and flights can be long
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		s := &Snapshot{}
+		if err := json.Unmarshal(scanner.Bytes(), s); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, scanner.Err()
+}
+
+// rotate moves the snapshots of dir aside once they reach
+// maxSnapshotsBytes, dropping the ones moved aside before.
+func rotate(dir string) error {
+	info, err := os.Stat(filepath.Join(dir, snapshotsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() < maxSnapshotsBytes {
+		return nil
+	}
+	return os.Rename(filepath.Join(dir, snapshotsFile), filepath.Join(dir, rotatedFile))
+}
+
+func (fs *FileStore) FlightLog(reg string) ([]*sites.FlightAttributes, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir, err := fs.regDir(reg)
+	if err != nil {
+		return nil, err
+	}
+	return fs.readFlights(dir)
+}
+
+func (fs *FileStore) readFlights(dir string) ([]*sites.FlightAttributes, error) {
+	b, err := os.ReadFile(filepath.Join(dir, flightsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return []*sites.FlightAttributes{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	flights := []*sites.FlightAttributes{}
+	err = json.Unmarshal(b, &flights)
+	return flights, err
+}
+
+func (fs *FileStore) regDir(reg string) (string, error) {
+	reg = normalizeReg(reg)
+	// registrations are used as directory names
+	if reg == "" || reg != filepath.Base(reg) || reg == "." || reg == ".." {
+		return "", fmt.Errorf("invalid registration %q", reg)
+	}
+	return filepath.Join(fs.dir, reg), nil
+}
+
+// WriteFileAtomic writes v as JSON next to path and renames it into
+// place so readers never see a partial file.
+func WriteFileAtomic(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, b, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+)
+
+var ErrNotFound = errors.New("no snapshots found")
+
+// Snapshot is the result of one scrape of an aircraft.
+type Snapshot struct {
+	Reg         string                   `json:"Reg"`
+	Time        time.Time                `json:"Time"`
+	JetPhotos   *sites.JetPhotosResult   `json:"JetPhotos"`
+	FlightRadar *sites.FlightRadarResult `json:"FlightRadar"`
+	// scraped in the default shape, newest photos and flights first
+	// with nothing filtered out, so it can be compared with the last
+	// canonical snapshot. Lookups made by clients never are.
+	Canonical bool `json:"Canonical,omitempty"`
+}
+
+type Store interface {
+	// Save records a snapshot and merges its flights into the flight log.
+	Save(s *Snapshot) error
+	// Latest returns the newest snapshot of reg, or ErrNotFound.
+	Latest(reg string) (*Snapshot, error)
+	// LatestCanonical returns the newest canonical snapshot of reg, or
+	// ErrNotFound.
+	LatestCanonical(reg string) (*Snapshot, error)
+	// Snapshots returns every snapshot of reg, oldest first.
+	Snapshots(reg string) ([]*Snapshot, error)
+	// FlightLog returns every flight seen for reg, newest first.
+	FlightLog(reg string) ([]*sites.FlightAttributes, error)
+}
+
+func NewSnapshot(reg string, sr *sites.ScrapeResult, t time.Time) *Snapshot {
+	return &Snapshot{
+		Reg:         normalizeReg(reg),
+		Time:        t,
+		JetPhotos:   sr.JetPhotos,
+		FlightRadar: sr.FlightRadar,
+	}
+}
+
+func normalizeReg(reg string) string {
+	return strings.ToUpper(strings.TrimSpace(reg))
+}
+
+// flightKey identifies a flight across snapshots, FR24 only shows a
+// flight number once per day.
+func flightKey(f *sites.FlightAttributes) string {
+	return f.Date + "|" + f.Flight
+}
+
+// mergeFlights adds the flights of a newer snapshot to log, replacing
+// entries already seen so their status stays current.
+func mergeFlights(log, flights []*sites.FlightAttributes) []*sites.FlightAttributes {
+	index := map[string]int{}
+	for i, f := range log {
+		index[flightKey(f)] = i
+	}
+
+	added := []*sites.FlightAttributes{}
+	for _, f := range flights {
+		if i, ok := index[flightKey(f)]; ok {
+			log[i] = f
+			continue
+		}
+		added = append(added, f)
+	}
+	// flights are newest first on FR24
+	return append(added, log...)
+}
+
+// SaveHook is called after a canonical snapshot is saved, with the
+// canonical snapshot of the same registration saved before it, or nil
+// if there is none.
+type SaveHook func(prev, next *Snapshot)
+
+type hookedStore struct {
+	Store
+	hook SaveHook
+
+	mu sync.Mutex
+	// held while a registration's snapshot is saved, so two saves
+	// can't both be compared with the same previous snapshot
+	regLocks map[string]*sync.Mutex
+}
+
+// WithHook wraps store so that hook runs after every Save of a
+// canonical snapshot. If the previous one can't be read, the snapshot
+// is saved without running hook and Save returns the error.
+func WithHook(store Store, hook SaveHook) Store {
+	return &hookedStore{
+		Store:    store,
+		hook:     hook,
+		regLocks: map[string]*sync.Mutex{},
+	}
+}
+
+func (hs *hookedStore) Save(s *Snapshot) error {
+	if !s.Canonical {
+		return hs.Store.Save(s)
+	}
+
+	lock := hs.regLock(s.Reg)
+	lock.Lock()
+	defer lock.Unlock()
+
+	prev, prevErr := hs.Store.LatestCanonical(s.Reg)
+	if errors.Is(prevErr, ErrNotFound) {
+		prev, prevErr = nil, nil
+	}
+
+	if err := hs.Store.Save(s); err != nil {
+		return err
+	}
+	if prevErr != nil {
+		// compared with nothing, every photo and flight would look new
+		return fmt.Errorf("saved without comparing, reading previous snapshot: %v", prevErr)
+	}
+	hs.hook(prev, s)
+	return nil
+}
+
+func (hs *hookedStore) regLock(reg string) *sync.Mutex {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	reg = normalizeReg(reg)
+	lock, ok := hs.regLocks[reg]
+	if !ok {
+		lock = &sync.Mutex{}
+		hs.regLocks[reg] = lock
+	}
+	return lock
+}
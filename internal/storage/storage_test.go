@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHookedSave(t *testing.T) {
+	tests := []struct {
+		name string
+		// run on the store directory before the second save
+		damage   func(t *testing.T, regDir string)
+		prev     bool
+		hooked   bool
+		saveErrs bool
+	}{
+		{
+			name:   "previous snapshot",
+			damage: func(*testing.T, string) {},
+			prev:   true,
+			hooked: true,
+		},
+		{
+			name: "no previous snapshot",
+			damage: func(t *testing.T, regDir string) {
+				if err := os.Remove(filepath.Join(regDir, canonicalFile)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			prev:   false,
+			hooked: true,
+		},
+		{
+			name: "unreadable previous snapshot",
+			damage: func(t *testing.T, regDir string) {
+				if err := os.WriteFile(filepath.Join(regDir, canonicalFile), []byte("{"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			hooked:   false,
+			saveErrs: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fs, err := NewFileStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var calls []*Snapshot
+			store := WithHook(fs, func(prev, next *Snapshot) {
+				calls = append(calls, prev)
+			})
+
+			now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			first := &Snapshot{Reg: "G-XLEA", Time: now, Canonical: true}
+			if err := store.Save(first); err != nil {
+				t.Fatal(err)
+			}
+			regDir, err := fs.regDir("G-XLEA")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(t, regDir)
+
+			calls = nil
+			second := &Snapshot{Reg: "G-XLEA", Time: now.Add(time.Hour), Canonical: true}
+			err = store.Save(second)
+			if (err != nil) != tt.saveErrs {
+				t.Errorf("Save returned %v", err)
+			}
+			if (len(calls) == 1) != tt.hooked {
+				t.Fatalf("hook ran %d times", len(calls))
+			}
+			if tt.hooked && (calls[0] != nil) != tt.prev {
+				t.Errorf("hook got previous snapshot %+v", calls[0])
+			}
+
+			latest, err := store.Latest("G-XLEA")
+			if err != nil || !latest.Time.Equal(second.Time) {
+				t.Errorf("Latest = %+v, %v, want the second snapshot", latest, err)
+			}
+		})
+	}
+}
+
+func TestLatestWithoutLatestFile(t *testing.T) {
+	fs, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Latest("G-XLEA"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Latest of an unknown reg returned %v", err)
+	}
+
+	if err := fs.Save(&Snapshot{Reg: "G-XLEA", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	regDir, err := fs.regDir("G-XLEA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(regDir, latestFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Latest("G-XLEA"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Latest without %s returned %v", latestFile, err)
+	}
+}
@@ -0,0 +1,118 @@
+package watch
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/changes"
+	"github.com/macsencasaus/jetapi/internal/sites"
+	"github.com/macsencasaus/jetapi/internal/storage"
+)
+
+// events buffered per subscriber before they start being dropped
+const subscriberBuffer = 32
+
+// Hub fans change events out to subscribers of a registration. While
+// a registration has subscribers it is refreshed once per interval, no
+// matter how many there are.
+type Hub struct {
+	store    storage.Store
+	interval time.Duration
+	errorLog *log.Logger
+
+	mu    sync.Mutex
+	feeds map[string]*feed
+}
+
+type feed struct {
+	subs   map[chan changes.Event]struct{}
+	cancel context.CancelFunc
+}
+
+func NewHub(store storage.Store, interval time.Duration, errorLog *log.Logger) *Hub {
+	return &Hub{
+		store:    store,
+		interval: interval,
+		errorLog: errorLog,
+		feeds:    map[string]*feed{},
+	}
+}
+
+// Subscribe returns a channel of the events of reg, and a function to
+// call once the caller stops reading.
+func (h *Hub) Subscribe(reg string) (<-chan changes.Event, func()) {
+	reg = normalizeReg(reg)
+	ch := make(chan changes.Event, subscriberBuffer)
+
+	h.mu.Lock()
+	f, ok := h.feeds[reg]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		f = &feed{subs: map[chan changes.Event]struct{}{}, cancel: cancel}
+		h.feeds[reg] = f
+		go h.refreshLoop(ctx, reg)
+	}
+	f.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(f.subs, ch)
+		if len(f.subs) == 0 && h.feeds[reg] == f {
+			f.cancel()
+			delete(h.feeds, reg)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Publish sends events to the subscribers of their registration.
+// Subscribers that fall behind miss events rather than block.
+func (h *Hub) Publish(events []changes.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, e := range events {
+		f, ok := h.feeds[e.Reg]
+		if !ok {
+			continue
+		}
+		for ch := range f.subs {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+func (h *Hub) refreshLoop(ctx context.Context, reg string) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		// saving the snapshot publishes its changes through the
+		// store hook
+		q := &sites.APIQueries{Reg: reg, Photos: 3, Flights: 20}
+		sr, err := sites.Scrape(q)
+		if sr != nil {
+			saveErr := h.store.Save(canonicalSnapshot(q, sr))
+			if saveErr != nil {
+				h.errorLog.Printf("saving snapshot of %s: %v", reg, saveErr)
+			}
+		}
+		if err != nil {
+			h.errorLog.Printf("refreshing %s for stream: %v", reg, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/storage"
+)
+
+var (
+	ErrNotWatched     = errors.New("registration is not watched")
+	ErrAlreadyWatched = errors.New("registration is already watched")
+)
+
+type Entry struct {
+	Reg     string    `json:"Reg"`
+	Photos  int       `json:"Photos"`
+	Flights int       `json:"Flights"`
+	Added   time.Time `json:"Added"`
+
+	LastRefresh time.Time `json:"LastRefresh"`
+	LastError   string    `json:"LastError"`
+}
+
+// List is the set of watched registrations, saved to a JSON file on
+// every change.
+type List struct {
+	path    string
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+func OpenList(path string) (*List, error) {
+	l := &List{path: path, entries: map[string]*Entry{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*Entry{}
+	if err = json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		l.entries[e.Reg] = e
+	}
+	return l, nil
+}
+
+// All returns a copy of every entry, sorted by registration.
+func (l *List) All() []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Reg < entries[j].Reg
+	})
+	return entries
+}
+
+func (l *List) Get(reg string) (Entry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	e, ok := l.entries[normalizeReg(reg)]
+	if !ok {
+		return Entry{}, ErrNotWatched
+	}
+	return *e, nil
+}
+
+func (l *List) Add(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Reg = normalizeReg(e.Reg)
+	if _, ok := l.entries[e.Reg]; ok {
+		return Entry{}, ErrAlreadyWatched
+	}
+	e.Added = time.Now()
+	e.LastRefresh = time.Time{}
+	e.LastError = ""
+	l.entries[e.Reg] = &e
+	return e, l.save()
+}
+
+// Update changes the scrape options of a watched registration.
+func (l *List) Update(reg string, photos, flights int) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[normalizeReg(reg)]
+	if !ok {
+		return Entry{}, ErrNotWatched
+	}
+	e.Photos = photos
+	e.Flights = flights
+	return *e, l.save()
+}
+
+func (l *List) Remove(reg string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reg = normalizeReg(reg)
+	if _, ok := l.entries[reg]; !ok {
+		return ErrNotWatched
+	}
+	delete(l.entries, reg)
+	return l.save()
+}
+
+func (l *List) refreshed(reg string, t time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[reg]
+	if !ok {
+		// removed while refreshing
+		return
+	}
+	e.LastRefresh = t
+	e.LastError = ""
+	if err != nil {
+		e.LastError = err.Error()
+	}
+	l.save()
+}
+
+// save must be called with l.mu held.
+func (l *List) save() error {
+	entries := make([]*Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Reg < entries[j].Reg
+	})
+	return storage.WriteFileAtomic(l.path, entries)
+}
+
+func normalizeReg(reg string) string {
+	return strings.ToUpper(strings.TrimSpace(reg))
+}
@@ -0,0 +1,127 @@
+package watch
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/sites"
+	"github.com/macsencasaus/jetapi/internal/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// upper bound on registrations refreshed at once
+const refreshWorkers = 4
+
+// Scheduler re-scrapes every watched registration about once per
+// interval and stores the results.
+type Scheduler struct {
+	list     *List
+	store    storage.Store
+	interval time.Duration
+	errorLog *log.Logger
+
+	// only touched by Run
+	next map[string]time.Time
+}
+
+func NewScheduler(list *List, store storage.Store, interval time.Duration, errorLog *log.Logger) *Scheduler {
+	return &Scheduler{
+		list:     list,
+		store:    store,
+		interval: interval,
+		errorLog: errorLog,
+		next:     map[string]time.Time{},
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(min(s.interval, 30*time.Second))
+	defer ticker.Stop()
+
+	for {
+		s.refreshDue(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) refreshDue(now time.Time) {
+	entries := s.list.All()
+
+	watched := map[string]bool{}
+	due := []Entry{}
+	for _, e := range entries {
+		watched[e.Reg] = true
+
+		next, ok := s.next[e.Reg]
+		if !ok {
+			// spread new registrations over the first interval
+			// instead of scraping them all at once
+			next = e.LastRefresh.Add(s.interval)
+			if next.Before(now) {
+				next = now.Add(rand.N(s.interval))
+			}
+			s.next[e.Reg] = next
+		}
+		if !now.Before(next) {
+			due = append(due, e)
+		}
+	}
+
+	for reg := range s.next {
+		if !watched[reg] {
+			delete(s.next, reg)
+		}
+	}
+
+	g := errgroup.Group{}
+	g.SetLimit(refreshWorkers)
+	for _, e := range due {
+		s.next[e.Reg] = now.Add(s.jittered())
+		g.Go(func() error {
+			s.refresh(e)
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+func (s *Scheduler) refresh(e Entry) {
+	q := &sites.APIQueries{Reg: e.Reg, Photos: e.Photos, Flights: e.Flights}
+	sr, err := sites.Scrape(q)
+	if sr != nil {
+		saveErr := s.store.Save(canonicalSnapshot(q, sr))
+		if saveErr != nil {
+			s.errorLog.Printf("saving snapshot of %s: %v", e.Reg, saveErr)
+		}
+	}
+	if err != nil {
+		s.errorLog.Printf("refreshing %s: %v", e.Reg, err)
+	}
+	s.list.refreshed(e.Reg, time.Now(), err)
+}
+
+// jittered returns the interval moved by up to a tenth either way.
+func (s *Scheduler) jittered() time.Duration {
+	jitter := s.interval / 10
+	if jitter <= 0 {
+		return s.interval
+	}
+	return s.interval - jitter + rand.N(2*jitter)
+}
+
+// canonicalSnapshot marks the snapshot of q, scraped with the default
+// queries, as canonical when it asked for both photos and flights.
+// Diffs only tell what is new from the newest entries, so a source
+// that wasn't asked for would make everything after it look new.
+func canonicalSnapshot(q *sites.APIQueries, sr *sites.ScrapeResult) *storage.Snapshot {
+	snapshot := storage.NewSnapshot(q.Reg, sr, time.Now())
+	snapshot.Canonical = q.Photos > 0 && q.Flights > 0
+	return snapshot
+}
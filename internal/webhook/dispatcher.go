@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/changes"
+)
+
+const (
+	SignatureHeader = "X-JetAPI-Signature"
+	EventHeader     = "X-JetAPI-Event"
+	EventIDHeader   = "X-JetAPI-Event-ID"
+	// unique to each attempt, unlike the event ID
+	DeliveryHeader = "X-JetAPI-Delivery"
+)
+
+const (
+	maxAttempts  = 5
+	firstBackoff = 2 * time.Second
+	// number of attempts kept in the delivery log
+	deliveryLogSize = 500
+)
+
+// Delivery is one attempt at sending an event to a hook.
+type Delivery struct {
+	ID        string            `json:"ID"`
+	HookID    string            `json:"HookID"`
+	EventID   string            `json:"EventID"`
+	EventType changes.EventType `json:"EventType"`
+	Attempt   int               `json:"Attempt"`
+	Time      time.Time         `json:"Time"`
+	Status    int               `json:"Status"`
+	Error     string            `json:"Error"`
+	Delivered bool              `json:"Delivered"`
+}
+
+type Dispatcher struct {
+	registry *Registry
+	client   *http.Client
+	errorLog *log.Logger
+
+	mu         sync.Mutex
+	deliveries []Delivery
+}
+
+func NewDispatcher(registry *Registry, errorLog *log.Logger) *Dispatcher {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !registry.allowPrivate {
+		// the address dialed is the one resolved for this connection,
+		// which also covers redirects and DNS changed after Add
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &Dispatcher{
+		registry: registry,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		errorLog: errorLog,
+	}
+}
+
+// Sign returns the signature header value of body, for receivers to
+// compare against.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch sends each event to every hook that wants it. Deliveries
+// run in the background, retrying with exponential backoff.
+func (d *Dispatcher) Dispatch(events []changes.Event) {
+	hooks := d.registry.all()
+	for _, e := range events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			d.errorLog.Printf("encoding event %s: %v", e.ID, err)
+			continue
+		}
+		for _, h := range hooks {
+			if h.wants(e.Reg) {
+				go d.deliver(h, e, body)
+			}
+		}
+	}
+}
+
+// Deliveries returns the delivery log, newest first.
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deliveries := make([]Delivery, len(d.deliveries))
+	for i, del := range d.deliveries {
+		deliveries[len(deliveries)-1-i] = del
+	}
+	return deliveries
+}
+
+func (d *Dispatcher) deliver(h Hook, e changes.Event, body []byte) {
+	backoff := firstBackoff
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		del := Delivery{
+			ID:        randomHex(16),
+			HookID:    h.ID,
+			EventID:   e.ID,
+			EventType: e.Type,
+			Attempt:   attempt,
+			Time:      time.Now(),
+		}
+
+		status, err := d.post(h, e, del.ID, body)
+		del.Status = status
+		if err != nil {
+			del.Error = err.Error()
+		}
+		del.Delivered = err == nil
+		d.record(del)
+
+		if del.Delivered {
+			return
+		}
+		if attempt < maxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	d.errorLog.Printf("giving up on event %s for webhook %s", e.ID, h.ID)
+}
+
+func (d *Dispatcher) post(h Hook, e changes.Event, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(h.Secret, body))
+	req.Header.Set(EventHeader, string(e.Type))
+	req.Header.Set(EventIDHeader, e.ID)
+	req.Header.Set(DeliveryHeader, deliveryID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(del Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.deliveries = append(d.deliveries, del)
+	if len(d.deliveries) > deliveryLogSize {
+		d.deliveries = d.deliveries[len(d.deliveries)-deliveryLogSize:]
+	}
+}
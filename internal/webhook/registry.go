@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/storage"
+)
+
+var (
+	ErrNotFound       = errors.New("webhook not found")
+	ErrPrivateAddress = errors.New("webhook URL is a private, loopback or link-local address")
+)
+
+type Hook struct {
+	ID  string `json:"ID"`
+	URL string `json:"URL"`
+	// key of the HMAC-SHA256 signature sent with every delivery
+	Secret string `json:"Secret"`
+	// registrations to notify about, all of them when empty
+	Regs    []string  `json:"Regs"`
+	Created time.Time `json:"Created"`
+	// name of the API key that registered the hook
+	Owner string `json:"Owner"`
+}
+
+func (h *Hook) wants(reg string) bool {
+	if len(h.Regs) == 0 {
+		return true
+	}
+	for _, r := range h.Regs {
+		if r == reg {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry is the set of registered webhooks, saved to a JSON file on
+// every change.
+type Registry struct {
+	path string
+	// let hooks post to private addresses, for trying them out locally
+	allowPrivate bool
+
+	mu    sync.RWMutex
+	hooks map[string]*Hook
+}
+
+func OpenRegistry(path string, allowPrivate bool) (*Registry, error) {
+	r := &Registry{path: path, allowPrivate: allowPrivate, hooks: map[string]*Hook{}}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := []*Hook{}
+	if err = json.Unmarshal(b, &hooks); err != nil {
+		return nil, err
+	}
+	for _, h := range hooks {
+		r.hooks[h.ID] = h
+	}
+	return r, nil
+}
+
+// Add registers a webhook of owner for URL with a new secret.
+func (r *Registry) Add(URL string, regs []string, owner string) (Hook, error) {
+	u, err := url.Parse(URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Hook{}, fmt.Errorf("invalid webhook URL %q", URL)
+	}
+	if !r.allowPrivate {
+		// checked again on every delivery, as DNS can change
+		ips, err := net.LookupIP(u.Hostname())
+		if err != nil {
+			return Hook{}, fmt.Errorf("resolving webhook URL %q: %v", URL, err)
+		}
+		for _, ip := range ips {
+			if !publicIP(ip) {
+				return Hook{}, ErrPrivateAddress
+			}
+		}
+	}
+
+	for i := range regs {
+		regs[i] = strings.ToUpper(strings.TrimSpace(regs[i]))
+	}
+
+	h := &Hook{
+		ID:      randomHex(8),
+		URL:     URL,
+		Secret:  randomHex(32),
+		Regs:    regs,
+		Created: time.Now(),
+		Owner:   owner,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[h.ID] = h
+	return *h, r.save()
+}
+
+// Get returns the hook with id, with its secret left out.
+func (r *Registry) Get(id string) (Hook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h, ok := r.hooks[id]
+	if !ok {
+		return Hook{}, ErrNotFound
+	}
+	hook := *h
+	hook.Secret = ""
+	return hook, nil
+}
+
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.hooks, id)
+	return r.save()
+}
+
+// All returns every hook with its secret left out.
+func (r *Registry) All() []Hook {
+	hooks := r.all()
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks
+}
+
+// Owned returns the hooks of owner with their secrets left out.
+func (r *Registry) Owned(owner string) []Hook {
+	hooks := []Hook{}
+	for _, h := range r.All() {
+		if h.Owner == owner {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+func (r *Registry) all() []Hook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hooks := make([]Hook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		hooks = append(hooks, *h)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].Created.Before(hooks[j].Created)
+	})
+	return hooks
+}
+
+// save must be called with r.mu held.
+func (r *Registry) save() error {
+	hooks := make([]*Hook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		hooks = append(hooks, h)
+	}
+	return storage.WriteFileAtomic(r.path, hooks)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// nonPublicPrefixes are the ranges of the IANA special-purpose address
+// registries that aren't globally reachable, or that embed another
+// address which may not be.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	// carrier-grade NAT
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	// benchmarking
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	// multicast, reserved and broadcast
+	netip.MustParsePrefix("224.0.0.0/3"),
+
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	// NAT64
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	// IETF protocol assignments, including Teredo
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	// 6to4
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// publicIP reports whether ip can be reached from the internet, ruling
+// out hooks aimed at this host or its network.
+func publicIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/macsencasaus/jetapi/internal/changes"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		// RFC 4231 test case 2
+		{
+			secret: "Jefe",
+			body:   "what do ya want for nothing?",
+			want:   "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		},
+		{
+			secret: "",
+			body:   "",
+			want:   "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %q) = %s, want %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"203.0.113.5", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::a00:1", false},
+		{"2002:a00:1::1", false},
+		{"2001:db8::1", false},
+		{"ff02::1", false},
+	}
+
+	for _, tt := range tests {
+		if got := publicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("publicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAddRejectsPrivateURLs(t *testing.T) {
+	r, err := OpenRegistry(filepath.Join(t.TempDir(), "webhooks.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"http://127.0.0.1:9090/", ErrPrivateAddress},
+		{"http://localhost/", ErrPrivateAddress},
+		{"http://169.254.169.254/latest/meta-data/", ErrPrivateAddress},
+		{"https://[::1]/", ErrPrivateAddress},
+	}
+
+	for _, tt := range tests {
+		_, err := r.Add(tt.url, nil, "owner")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Add(%s) returned %v, want %v", tt.url, err, tt.want)
+		}
+	}
+
+	for _, url := range []string{"ftp://example.com/", "not a url", "http://"} {
+		if _, err := r.Add(url, nil, "owner"); err == nil {
+			t.Errorf("Add(%s) succeeded", url)
+		}
+	}
+}
+
+func TestOwned(t *testing.T) {
+	r, err := OpenRegistry(filepath.Join(t.TempDir(), "webhooks.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mine, err := r.Add("http://127.0.0.1:9090/", []string{" g-xlea "}, "mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Add("http://127.0.0.1:9091/", nil, "theirs"); err != nil {
+		t.Fatal(err)
+	}
+
+	owned := r.Owned("mine")
+	if len(owned) != 1 || owned[0].ID != mine.ID || owned[0].Secret != "" {
+		t.Fatalf("Owned(mine) = %+v", owned)
+	}
+	if !owned[0].wants("G-XLEA") || owned[0].wants("G-XLEB") {
+		t.Errorf("hook for %v wants the wrong registrations", owned[0].Regs)
+	}
+}
+
+func TestDispatchHeaders(t *testing.T) {
+	type received struct {
+		eventID, delivery, signature string
+	}
+	got := make(chan received, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- received{
+			eventID:   r.Header.Get(EventIDHeader),
+			delivery:  r.Header.Get(DeliveryHeader),
+			signature: r.Header.Get(SignatureHeader),
+		}
+	}))
+	defer srv.Close()
+
+	r, err := OpenRegistry(filepath.Join(t.TempDir(), "webhooks.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, owner := range []string{"a", "b"} {
+		if _, err := r.Add(srv.URL, nil, owner); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := NewDispatcher(r, log.New(io.Discard, "", 0))
+	d.Dispatch([]changes.Event{{ID: "event1", Type: changes.PhotoAdded, Reg: "G-XLEA"}})
+
+	deliveries := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case rec := <-got:
+			if rec.eventID != "event1" || rec.delivery == "" || rec.signature == "" {
+				t.Errorf("received %+v", rec)
+			}
+			deliveries[rec.delivery] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("delivery not received")
+		}
+	}
+	if len(deliveries) != 2 {
+		t.Errorf("both hooks got delivery ID %v", deliveries)
+	}
+}